@@ -0,0 +1,200 @@
+// gpctl 面板的命令行管理工具，直接读写数据目录中的数据库，无需面板运行
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/service"
+)
+
+// passphraseEnv 加密导出包口令的环境变量，也可通过 -passphrase-file 指定
+const passphraseEnv = "GPANEL_BUNDLE_PASSPHRASE"
+
+const usage = `Usage: gpctl <command> [options]
+
+Commands:
+  settings export [-data dir] [-format yaml|json] [-secrets exclude|encrypt] [-passphrase-file file] [-o file]
+  settings import [-data dir] [-dry-run] [-passphrase-file file] <file|->
+
+The data directory defaults to database.data_dir from config.yaml or $GPANEL_DATABASE_DATA_DIR.
+The passphrase for encrypted secrets is read from -passphrase-file or $` + passphraseEnv + `.
+Changes made by gpctl take effect after the panel is restarted or POST /api/v1/config/reload is called.
+`
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "settings" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// 与面板使用相同的配置文件和环境变量确定数据目录，日志输出到标准错误以免混入导出内容
+	if err := global.LoadBootstrapConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	global.LogWriter = os.Stderr
+
+	var err error
+	switch os.Args[2] {
+	case "export":
+		err = exportSettings(os.Args[3:])
+	case "import":
+		err = importSettings(os.Args[3:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func exportSettings(args []string) error {
+	fs := flag.NewFlagSet("settings export", flag.ExitOnError)
+	dataDir := fs.String("data", global.DataDir, "data directory")
+	format := fs.String("format", "yaml", "bundle format: yaml or json")
+	secrets := fs.String("secrets", service.BundleSecretsExclude, "secret settings: exclude or encrypt")
+	passphraseFile := fs.String("passphrase-file", "", "file containing the bundle passphrase")
+	output := fs.String("o", "-", "output file, - for stdout")
+	_ = fs.Parse(args)
+
+	if *format != "yaml" && *format != "json" {
+		return errors.New("unsupported format: " + *format)
+	}
+	passphrase, err := readPassphrase(*passphraseFile)
+	if err != nil {
+		return err
+	}
+	if err := openDatabase(*dataDir); err != nil {
+		return err
+	}
+	defer global.CloseDB()
+
+	bundle, err := service.NewSettingBundleService().Export(*secrets, passphrase)
+	if err != nil {
+		return err
+	}
+	data, err := service.EncodeSettingBundle(bundle, *format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0600)
+}
+
+func importSettings(args []string) error {
+	fs := flag.NewFlagSet("settings import", flag.ExitOnError)
+	dataDir := fs.String("data", global.DataDir, "data directory")
+	dryRun := fs.Bool("dry-run", false, "only show the differences")
+	passphraseFile := fs.String("passphrase-file", "", "file containing the bundle passphrase")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("a bundle file is required, use - to read from stdin")
+	}
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	bundle, err := service.DecodeSettingBundle(data)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase(*passphraseFile)
+	if err != nil {
+		return err
+	}
+	values, err := bundle.Values(passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := openDatabase(*dataDir); err != nil {
+		return err
+	}
+	defer global.CloseDB()
+
+	result, err := service.NewSettingBundleService().Import(values, bundle.Custom, *dryRun, service.SettingActor{Username: "gpctl"})
+	if err != nil {
+		var validationErr *service.SettingValidationError
+		if errors.As(err, &validationErr) {
+			keys := make([]string, 0, len(validationErr.Fields))
+			for key := range validationErr.Fields {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(os.Stderr, "  %s: %s\n", key, validationErr.Fields[key])
+			}
+			return errors.New("invalid settings")
+		}
+		return err
+	}
+
+	for _, diff := range result.Changes {
+		if diff.Action == service.SettingDiffAdd {
+			fmt.Printf("+ %s = %q\n", diff.Key, diff.After)
+			continue
+		}
+		fmt.Printf("~ %s: %q -> %q\n", diff.Key, diff.Before, diff.After)
+	}
+	switch {
+	case len(result.Changes) == 0:
+		fmt.Println("No changes.")
+	case result.DryRun:
+		fmt.Printf("%d setting(s) would change (dry run).\n", len(result.Changes))
+	default:
+		fmt.Printf("%d setting(s) changed: %s\n", len(result.Changed), strings.Join(result.Changed, ", "))
+		if service.RestartRequired(result.Changed) {
+			fmt.Println("Some settings require a restart to take effect.")
+		}
+	}
+	return nil
+}
+
+// openDatabase 打开数据目录中已有的数据库并加载主密钥，用于解密和加密敏感设置。
+// 二者都不会自动创建：数据目录写错时直接报错，而不是生成空数据库或无法解密已有设置的新主密钥
+func openDatabase(dataDir string) error {
+	global.DataDir = dataDir
+	if _, err := os.Stat(global.DBPath()); err != nil {
+		return fmt.Errorf("database not found: %w", err)
+	}
+	if err := global.InitDB(); err != nil {
+		return err
+	}
+	if err := global.OpenMasterKey(); err != nil {
+		return fmt.Errorf("load master key: %w", err)
+	}
+	return global.DB.AutoMigrate(&models.Setting{}, &models.SettingRevision{})
+}
+
+func readPassphrase(file string) (string, error) {
+	if file == "" {
+		return os.Getenv(passphraseEnv), nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
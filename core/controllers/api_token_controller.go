@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/models"
+	"gpanel/service"
+)
+
+type APITokenController struct {
+	apiTokenService service.IAPITokenService
+}
+
+func NewAPITokenController() *APITokenController {
+	return &APITokenController{
+		apiTokenService: service.NewAPITokenService(),
+	}
+}
+
+// CreateToken 创建个人访问令牌，明文令牌仅在创建时返回一次
+func (tc *APITokenController) CreateToken(c *gin.Context) {
+	var req struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes" binding:"required"`
+		ExpiresInDays int      `json:"expiresInDays"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	user, err := service.NewUserService().GetByID(c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	// expiresInDays 为 0 表示永不过期
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &t
+	}
+
+	raw, token, err := tc.apiTokenService.Create(user, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidScope) || errors.Is(err, service.ErrAPITokenName) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":    raw,
+		"apiToken": token,
+	})
+}
+
+// ListTokens 列出当前用户的令牌，管理员可通过 all=true 查看所有用户的令牌
+func (tc *APITokenController) ListTokens(c *gin.Context) {
+	var tokens []models.APIToken
+	var err error
+	if c.Query("all") == "true" && models.RoleHasPermission(c.GetString("role"), models.PermUsersManage) {
+		tokens, err = tc.apiTokenService.ListAll()
+	} else {
+		tokens, err = tc.apiTokenService.List(c.GetUint("userID"))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get tokens",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+		"scopes": models.RolePermissions[c.GetString("role")],
+	})
+}
+
+func (tc *APITokenController) RevokeToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid token id",
+		})
+		return
+	}
+
+	anyUser := models.RoleHasPermission(c.GetString("role"), models.PermUsersManage)
+	if err := tc.apiTokenService.Revoke(uint(id), c.GetUint("userID"), anyUser); err != nil {
+		if errors.Is(err, service.ErrAPITokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Token not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Token revoked successfully",
+	})
+}
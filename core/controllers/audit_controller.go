@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/repo"
+	"gpanel/service"
+)
+
+// maxAuditPageSize 审计日志每页最大条数
+const maxAuditPageSize = 200
+
+type AuditController struct {
+	auditService service.IAuditService
+}
+
+func NewAuditController() *AuditController {
+	return &AuditController{
+		auditService: service.NewAuditService(),
+	}
+}
+
+// ListAuditLogs 分页查询审计日志，支持按用户、IP、方法、路由、状态码和时间范围过滤
+func (ac *AuditController) ListAuditLogs(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if pageSize < 1 || pageSize > maxAuditPageSize {
+		pageSize = 20
+	}
+
+	logs, total, err := ac.auditService.List(filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get audit logs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":     logs,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// ExportAuditLogs 按过滤条件导出审计日志，format 为 csv 或 json
+func (ac *AuditController) ExportAuditLogs(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported export format",
+		})
+		return
+	}
+
+	logs, err := ac.auditService.Export(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export audit logs",
+		})
+		return
+	}
+
+	filename := "audit-" + time.Now().Format("20060102-150405") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		c.JSON(http.StatusOK, logs)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write([]string{"id", "time", "user_id", "username", "ip", "method", "route", "path", "status", "changes"})
+	for _, entry := range logs {
+		changes := ""
+		if len(entry.Changes) > 0 {
+			if b, err := json.Marshal(entry.Changes); err == nil {
+				changes = string(b)
+			}
+		}
+		_ = writer.Write([]string{
+			strconv.FormatUint(uint64(entry.ID), 10),
+			entry.CreatedAt.Format(time.RFC3339),
+			strconv.FormatUint(uint64(entry.UserID), 10),
+			entry.Username,
+			entry.IP,
+			entry.Method,
+			entry.Route,
+			entry.Path,
+			strconv.Itoa(entry.Status),
+			changes,
+		})
+	}
+	writer.Flush()
+}
+
+func parseAuditFilter(c *gin.Context) (repo.AuditLogFilter, bool) {
+	filter := repo.AuditLogFilter{
+		Username: c.Query("username"),
+		IP:       c.Query("ip"),
+		Method:   strings.ToUpper(c.Query("method")),
+		Route:    c.Query("route"),
+	}
+
+	if value := c.Query("status"); value != "" {
+		status, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid status",
+			})
+			return filter, false
+		}
+		filter.Status = status
+	}
+
+	for _, item := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		value := c.Query(item.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid time, expected RFC3339: " + item.name,
+			})
+			return filter, false
+		}
+		*item.target = &t
+	}
+	return filter, true
+}
@@ -1,17 +1,34 @@
 package controllers
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"gpanel/global"
+	"gpanel/models"
+	"gpanel/service"
+	"gpanel/utils"
 )
 
-var jwtSecret = []byte("gpanel-secret-key-change-in-production")
+const (
+	// tokenTypeAccess 正常访问 token
+	tokenTypeAccess = "access"
+	// tokenTypePreAuth 密码验证通过、等待双因素验证的临时 token
+	tokenTypePreAuth = "pre_auth"
+	// preAuthTokenTTL 临时 token 有效期
+	preAuthTokenTTL = 5 * time.Minute
+	// refreshCookieName 刷新令牌 cookie，仅在认证相关接口下发送
+	refreshCookieName = "gpanel_refresh"
+	refreshCookiePath = "/api/v1/auth"
+	// accessCookiePath cookie 模式下访问 token 只发送给接口
+	accessCookiePath = "/api"
+)
 
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -22,9 +39,14 @@ type LoginResponse struct {
 	Token string `json:"token"`
 }
 
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:])
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	PreAuthToken string `json:"preAuthToken" binding:"required"`
+	Code         string `json:"code" binding:"required"`
 }
 
 func Login(c *gin.Context) {
@@ -36,34 +58,31 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 从配置缓存中获取用户名和密码
-	panelUser := "admin"
-	panelPassword := "admin123"
-	if global.ConfigCacheInstance != nil {
-		panelUser = global.ConfigCacheInstance.GetPanelUser()
-		panelPassword = global.ConfigCacheInstance.GetPanelPassword()
-	}
-
-	// 验证用户名和密码
-	if req.Username == panelUser && hashPassword(req.Password) == hashPassword(panelPassword) {
-		// 获取会话超时时间（秒）
-		sessionTimeout := 86400 // 默认 24 小时
-		configVersion := ""
-		if global.ConfigCacheInstance != nil {
-			sessionTimeout = global.ConfigCacheInstance.GetSessionTimeout()
-			configVersion = global.ConfigCacheInstance.GetVersion()
-		}
+	c.Set("auditUsername", req.Username)
 
-		// 生成 JWT token，设置过期时间和配置版本
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"username":       req.Username,
-			"role":           "admin",
-			"exp":            time.Now().Add(time.Duration(sessionTimeout) * time.Second).Unix(),
-			"iat":            time.Now().Unix(),
-			"config_version": configVersion,
+	// 暴力破解防护：IP 或用户名处于锁定中时直接拒绝
+	throttle := service.NewLoginThrottleService()
+	loginEvents := service.NewLoginEventService()
+	if retryAfter, blocked := throttle.Reserve(c.ClientIP(), req.Username); blocked {
+		loginEvents.RecordFailure(req.Username, c.ClientIP(), c.Request.UserAgent(), models.LoginMethodPassword, "locked")
+		respondTooManyAttempts(c, retryAfter)
+		return
+	}
+
+	user, err := service.AuthenticateLogin(req.Username, req.Password)
+	if err != nil {
+		throttle.RecordFailure(c.ClientIP(), req.Username)
+		loginEvents.RecordFailure(req.Username, c.ClientIP(), c.Request.UserAgent(), models.LoginMethodPassword, "invalid_credentials")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid username or password",
 		})
+		return
+	}
 
-		tokenString, err := token.SignedString(jwtSecret)
+	// 已启用双因素认证，先签发临时 token，等待验证码
+	if service.NewTwoFactorService().IsEnabled(user.Username) {
+		throttle.Release(c.ClientIP(), req.Username)
+		preAuthToken, err := signPreAuthToken(user)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to generate token",
@@ -72,12 +91,336 @@ func Login(c *gin.Context) {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"token": tokenString,
+			"twoFactorRequired": true,
+			"preAuthToken":      preAuthToken,
+		})
+		return
+	}
+
+	// 强制双因素认证但尚未绑定，签发仅能用于绑定的受限 token
+	throttle.RecordSuccess(c.ClientIP(), req.Username)
+	loginEvents.RecordSuccess(user, c.ClientIP(), c.Request.UserAgent(), models.LoginMethodPassword)
+	setupRequired := global.ConfigCacheInstance != nil && global.ConfigCacheInstance.GetTwoFactorRequired()
+	respondWithToken(c, user, setupRequired)
+}
+
+// LoginTwoFactor 登录第二步，校验临时 token 和 TOTP 验证码（或恢复码）
+func LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := global.JWTKeys.Parse(req.PreAuthToken, claims)
+	if err != nil || !token.Valid || claims["typ"] != tokenTypePreAuth {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Login session expired, please login again",
+		})
+		return
+	}
+
+	username, _ := claims["username"].(string)
+	c.Set("auditUsername", username)
+	user, err := service.NewUserService().GetByUsername(username)
+	if err != nil || user.Disabled {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Login session expired, please login again",
+		})
+		return
+	}
+
+	// 验证码同样计入失败次数，防止暴力猜测
+	throttle := service.NewLoginThrottleService()
+	loginEvents := service.NewLoginEventService()
+	if retryAfter, blocked := throttle.Reserve(c.ClientIP(), user.Username); blocked {
+		loginEvents.RecordFailure(user.Username, c.ClientIP(), c.Request.UserAgent(), models.LoginMethodTwoFactor, "locked")
+		respondTooManyAttempts(c, retryAfter)
+		return
+	}
+
+	if err := service.NewTwoFactorService().Verify(user.Username, req.Code); err != nil {
+		throttle.RecordFailure(c.ClientIP(), user.Username)
+		loginEvents.RecordFailure(user.Username, c.ClientIP(), c.Request.UserAgent(), models.LoginMethodTwoFactor, "invalid_code")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid verification code",
+		})
+		return
+	}
+
+	throttle.RecordSuccess(c.ClientIP(), user.Username)
+	loginEvents.RecordSuccess(user, c.ClientIP(), c.Request.UserAgent(), models.LoginMethodTwoFactor)
+	respondWithToken(c, user, false)
+}
+
+// signPreAuthToken 生成等待双因素验证的临时 token
+func signPreAuthToken(user *models.User) (string, error) {
+	return global.JWTKeys.Sign(jwt.MapClaims{
+		"uid":      user.ID,
+		"username": user.Username,
+		"typ":      tokenTypePreAuth,
+		"exp":      time.Now().Add(preAuthTokenTTL).Unix(),
+		"iat":      time.Now().Unix(),
+	})
+}
+
+// respondTooManyAttempts 返回 429 及 Retry-After
+func respondTooManyAttempts(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      "Too many failed login attempts, please try again later",
+		"retryAfter": seconds,
+	})
+}
+
+// respondWithToken 签发访问 token 并返回登录结果
+func respondWithToken(c *gin.Context, user *models.User, twoFactorSetup bool) {
+	tokenString, err := issueAccessToken(c, user, twoFactorSetup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+
+	writeTokenResponse(c, user, tokenString, twoFactorSetup)
+}
+
+func writeTokenResponse(c *gin.Context, user *models.User, tokenString string, twoFactorSetup bool) {
+	// 供审计日志记录登录用户
+	c.Set("userID", user.ID)
+
+	response := gin.H{}
+	if err := putAccessToken(c, response, tokenString); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+	if twoFactorSetup {
+		response["twoFactorSetupRequired"] = true
+	}
+	if service.MustChangePassword(user) {
+		response["mustChangePassword"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// issueAccessToken 登记服务端会话，将刷新令牌写入 HttpOnly cookie，并生成短期访问 token
+func issueAccessToken(c *gin.Context, user *models.User, twoFactorSetup bool) (string, error) {
+	// 获取会话超时时间（秒），刷新令牌无法超过该时间续期
+	sessionTimeout := 86400 // 默认 24 小时
+	if global.ConfigCacheInstance != nil {
+		sessionTimeout = global.ConfigCacheInstance.GetSessionTimeout()
+	}
+
+	expiresAt := time.Now().Add(time.Duration(sessionTimeout) * time.Second)
+	session, refreshToken, err := service.NewSessionService().Create(user, c.ClientIP(), c.Request.UserAgent(), expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	setRefreshCookie(c, refreshToken, session.ExpiresAt)
+	return signAccessToken(user, session, twoFactorSetup)
+}
+
+// signAccessToken 生成访问 token，过期时间不超过会话的绝对过期时间。
+// 配置版本取自会话创建时，配置重新加载后刷新也无法绕过重新登录
+func signAccessToken(user *models.User, session *models.Session, twoFactorSetup bool) (string, error) {
+	accessTokenTTL := 900
+	if global.ConfigCacheInstance != nil {
+		accessTokenTTL = global.ConfigCacheInstance.GetAccessTokenTTL()
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(accessTokenTTL) * time.Second)
+	if accessTokenTTL <= 0 || expiresAt.After(session.ExpiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
+	claims := jwt.MapClaims{
+		"jti":            session.JTI,
+		"uid":            user.ID,
+		"username":       user.Username,
+		"role":           user.Role,
+		"typ":            tokenTypeAccess,
+		"exp":            expiresAt.Unix(),
+		"iat":            now.Unix(),
+		"config_version": session.ConfigVersion,
+	}
+	if twoFactorSetup {
+		claims["tfa_setup"] = true
+	}
+
+	return global.JWTKeys.Sign(claims)
+}
+
+// putAccessToken 访问 token 默认放入响应体；cookie 模式下写入 HttpOnly cookie，并重新生成 CSRF token
+func putAccessToken(c *gin.Context, response gin.H, tokenString string) error {
+	if !service.CookieAuthEnabled() {
+		response["token"] = tokenString
+		return nil
+	}
+
+	csrfToken, err := service.NewCSRFToken()
+	if err != nil {
+		return err
+	}
+
+	secure := utils.IsHTTPS(c.Request)
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(service.AccessCookieName, tokenString, 0, accessCookiePath, "", secure, true)
+	// CSRF token 需要由前端读取，不能设置 HttpOnly
+	c.SetCookie(service.CSRFCookieName, csrfToken, 0, "/", "", secure, false)
+	response["authMode"] = service.AuthModeCookie
+	return nil
+}
+
+// clearAccessCookies 清除 cookie 模式下的访问 token 和 CSRF token
+func clearAccessCookies(c *gin.Context) {
+	secure := utils.IsHTTPS(c.Request)
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(service.AccessCookieName, "", -1, accessCookiePath, "", secure, true)
+	c.SetCookie(service.CSRFCookieName, "", -1, "/", "", secure, false)
+}
+
+// setRefreshCookie 刷新令牌只发送给认证接口，脚本无法读取
+func setRefreshCookie(c *gin.Context, refreshToken string, expiresAt time.Time) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(refreshCookieName, refreshToken, int(time.Until(expiresAt).Seconds()), refreshCookiePath, "", utils.IsHTTPS(c.Request), true)
+}
+
+func clearRefreshCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(refreshCookieName, "", -1, refreshCookiePath, "", utils.IsHTTPS(c.Request), true)
+}
+
+// RefreshToken 使用 cookie 中的刷新令牌换发访问 token，刷新令牌同时轮换
+func RefreshToken(c *gin.Context) {
+	refreshToken, err := c.Cookie(refreshCookieName)
+	if err != nil || refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Session expired, please login again",
+		})
+		return
+	}
+
+	sessions := service.NewSessionService()
+	session, newRefreshToken, err := sessions.Refresh(refreshToken)
+	if errors.Is(err, service.ErrRefreshTokenRetry) {
+		// 并发请求已轮换了该令牌并写入新 cookie，保留 cookie，由客户端重试
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Refresh token was just rotated, please retry",
+			"code":  "refresh_retry",
+		})
+		return
+	}
+	if err != nil {
+		clearRefreshCookie(c)
+		clearAccessCookies(c)
+		message := "Session expired, please login again"
+		if errors.Is(err, service.ErrSessionConfigStale) {
+			message = "Configuration has been changed, please login again"
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": message,
+		})
+		return
+	}
+
+	// 用户被禁用或删除时吊销会话，不再下发新的刷新令牌
+	user, err := service.NewUserService().GetByID(session.UserID)
+	if err != nil || user.Disabled {
+		if err := sessions.Revoke(session.ID, session.UserID, true); err != nil {
+			log.Printf("Failed to revoke session %d of disabled user: %v", session.ID, err)
+		}
+		clearRefreshCookie(c)
+		clearAccessCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User is disabled or no longer exists",
 		})
 		return
 	}
+	setRefreshCookie(c, newRefreshToken, session.ExpiresAt)
 
-	c.JSON(http.StatusUnauthorized, gin.H{
-		"error": "Invalid username or password",
+	// 强制双因素认证但尚未绑定时，继续签发受限 token
+	twoFactorSetup := twoFactorRequired() && !service.NewTwoFactorService().IsEnabled(user.Username)
+	tokenString, err := signAccessToken(user, session, twoFactorSetup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+
+	writeTokenResponse(c, user, tokenString, twoFactorSetup)
+}
+
+// ChangePassword 修改当前用户的密码，成功后吊销该用户所有会话并为当前客户端签发新 token
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	// 当前密码校验失败同样计入失败次数，防止会话被盗用后暴力猜测密码
+	username := c.GetString("username")
+	throttle := service.NewLoginThrottleService()
+	if retryAfter, blocked := throttle.Reserve(c.ClientIP(), username); blocked {
+		respondTooManyAttempts(c, retryAfter)
+		return
+	}
+
+	user, err := service.NewUserService().ChangePassword(c.GetUint("userID"), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, service.ErrIncorrectPassword) {
+			throttle.RecordFailure(c.ClientIP(), username)
+		} else {
+			throttle.Release(c.ClientIP(), username)
+		}
+		respondUserError(c, err)
+		return
+	}
+	throttle.Release(c.ClientIP(), username)
+
+	respondWithToken(c, user, c.GetBool("twoFactorSetup"))
+}
+
+// RotateJWTKey 轮换 JWT 签名密钥，旧密钥签发的 token 在过期前仍然有效
+func RotateJWTKey(c *gin.Context) {
+	kid, err := global.JWTKeys.Rotate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to rotate signing key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Signing key rotated successfully",
+		"kid":     kid,
 	})
-}
\ No newline at end of file
+}
+
+// GetCurrentUser 返回当前登录用户信息及权限
+func GetCurrentUser(c *gin.Context) {
+	user, err := service.NewUserService().GetByID(c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":        user,
+		"permissions": models.RolePermissions[user.Role],
+	})
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/service"
+)
+
+// setupRefreshTest 创建用户和会话，返回会话和刷新令牌
+func setupRefreshTest(t *testing.T) (*models.User, *models.Session, string) {
+	t.Helper()
+
+	setupSettingTestDB(t)
+	if err := global.DB.AutoMigrate(&models.User{}, &models.TwoFactor{}, &models.Session{}, &models.RefreshToken{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := global.InitJWTKeys(); err != nil {
+		t.Fatalf("init jwt keys: %v", err)
+	}
+	user := &models.User{Username: "alice", Role: models.RoleAdmin}
+	if err := global.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	session, refreshToken, err := service.NewSessionService().Create(user, "10.0.0.1", "ua", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, session, refreshToken
+}
+
+func refresh(refreshToken string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/auth/refresh", RefreshToken)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: refreshCookieName, Value: refreshToken})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// refreshCookie 返回响应中设置的刷新令牌 cookie
+func refreshCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == refreshCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestRefreshTokenRejectsDisabledUser(t *testing.T) {
+	user, session, refreshToken := setupRefreshTest(t)
+	// 绕过用户服务直接禁用，模拟会话尚未被吊销的情况
+	if err := global.DB.Model(user).Update("disabled", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := refresh(refreshToken)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401: %s", w.Code, w.Body.String())
+	}
+	if cookie := refreshCookie(w); cookie == nil || cookie.Value != "" {
+		t.Fatalf("refresh cookie = %v, want cleared", cookie)
+	}
+	var stored models.Session
+	if err := global.DB.First(&stored, session.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.RevokedAt == nil {
+		t.Fatal("session of a disabled user was not revoked")
+	}
+}
+
+func TestRefreshTokenRetryWithinGrace(t *testing.T) {
+	_, _, refreshToken := setupRefreshTest(t)
+
+	if w := refresh(refreshToken); w.Code != http.StatusOK {
+		t.Fatalf("first refresh status = %d: %s", w.Code, w.Body.String())
+	}
+	// 并发请求使用同一个令牌时要求重试，不清除已轮换的新 cookie
+	w := refresh(refreshToken)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", w.Code, w.Body.String())
+	}
+	if cookie := refreshCookie(w); cookie != nil {
+		t.Fatalf("refresh cookie changed on retry: %v", cookie)
+	}
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/models"
+	"gpanel/service"
+)
+
+type LockoutController struct {
+	throttleService service.ILoginThrottleService
+}
+
+func NewLockoutController() *LockoutController {
+	return &LockoutController{
+		throttleService: service.NewLoginThrottleService(),
+	}
+}
+
+// lockoutView 登录失败记录，标记当前是否处于锁定中
+type lockoutView struct {
+	models.LoginThrottle
+	Locked bool `json:"locked"`
+}
+
+func (lc *LockoutController) ListLockouts(c *gin.Context) {
+	throttles, err := lc.throttleService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get lockouts",
+		})
+		return
+	}
+
+	now := time.Now()
+	views := make([]lockoutView, 0, len(throttles))
+	for _, throttle := range throttles {
+		views = append(views, lockoutView{
+			LoginThrottle: throttle,
+			Locked:        throttle.BlockedUntil != nil && throttle.BlockedUntil.After(now),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lockouts": views,
+	})
+}
+
+func (lc *LockoutController) ClearLockout(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid lockout id",
+		})
+		return
+	}
+
+	if err := lc.throttleService.Clear(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to clear lockout",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Lockout cleared successfully",
+	})
+}
+
+func (lc *LockoutController) ClearAllLockouts(c *gin.Context) {
+	if err := lc.throttleService.ClearAll(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to clear lockouts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "All lockouts cleared successfully",
+	})
+}
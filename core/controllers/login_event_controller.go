@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/repo"
+	"gpanel/service"
+)
+
+// maxLoginEventPageSize 登录记录每页最大条数
+const maxLoginEventPageSize = 200
+
+type LoginEventController struct {
+	loginEventService service.ILoginEventService
+}
+
+func NewLoginEventController() *LoginEventController {
+	return &LoginEventController{
+		loginEventService: service.NewLoginEventService(),
+	}
+}
+
+// ListMyLoginEvents 当前用户的登录记录
+func (lc *LoginEventController) ListMyLoginEvents(c *gin.Context) {
+	filter, ok := parseLoginEventFilter(c)
+	if !ok {
+		return
+	}
+	filter.UserID = c.GetUint("userID")
+	filter.Username = ""
+	lc.respondLoginEvents(c, filter)
+}
+
+// ListLoginEvents 所有用户的登录记录，支持按用户、IP、结果和时间范围过滤
+func (lc *LoginEventController) ListLoginEvents(c *gin.Context) {
+	filter, ok := parseLoginEventFilter(c)
+	if !ok {
+		return
+	}
+	lc.respondLoginEvents(c, filter)
+}
+
+func (lc *LoginEventController) respondLoginEvents(c *gin.Context, filter repo.LoginEventFilter) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if pageSize < 1 || pageSize > maxLoginEventPageSize {
+		pageSize = 20
+	}
+
+	events, total, err := lc.loginEventService.List(filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get login history",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":   events,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+func parseLoginEventFilter(c *gin.Context) (repo.LoginEventFilter, bool) {
+	filter := repo.LoginEventFilter{
+		Username: c.Query("username"),
+		IP:       c.Query("ip"),
+	}
+
+	if value := c.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid success filter",
+			})
+			return filter, false
+		}
+		filter.Success = &success
+	}
+
+	for _, item := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		value := c.Query(item.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid time, expected RFC3339: " + item.name,
+			})
+			return filter, false
+		}
+		*item.target = &t
+	}
+	return filter, true
+}
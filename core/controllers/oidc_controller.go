@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/service"
+	"gpanel/utils"
+)
+
+// oidcCallbackPath state cookie 只发送给回调接口
+const oidcCallbackPath = "/api/v1/auth/oidc/callback"
+
+type OIDCController struct {
+	oidcService service.IOIDCService
+}
+
+func NewOIDCController() *OIDCController {
+	return &OIDCController{
+		oidcService: service.NewOIDCService(),
+	}
+}
+
+// GetConfig 登录页使用，仅返回是否启用和显示名称
+func (oc *OIDCController) GetConfig(c *gin.Context) {
+	config := oc.oidcService.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": config.Enabled && config.Issuer != "" && config.ClientID != "" && config.RedirectURL != "",
+		"name":    config.ProviderName,
+	})
+}
+
+// Login 跳转到身份提供商的授权页面
+func (oc *OIDCController) Login(c *gin.Context) {
+	authURL, state, err := oc.oidcService.AuthorizationURL(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to start oidc login: %v", err)
+		redirectLoginPage(c, "oidcError", "Single sign-on is unavailable")
+		return
+	}
+
+	// 身份提供商回调是跨站的顶级跳转，SameSite=Lax 的 cookie 仍会随回调发送
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(service.OIDCStateCookieName, service.OIDCStateCookieValue(state), service.OIDCStateCookieMaxAge, oidcCallbackPath, "", utils.IsHTTPS(c.Request), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 身份提供商回调，登录成功后刷新令牌写入 cookie，由登录页换取访问 token
+func (oc *OIDCController) Callback(c *gin.Context) {
+	c.Set("auditForce", true)
+
+	stateCookie, _ := c.Cookie(service.OIDCStateCookieName)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(service.OIDCStateCookieName, "", -1, oidcCallbackPath, "", utils.IsHTTPS(c.Request), true)
+
+	loginEvents := service.NewLoginEventService()
+	if errCode := c.Query("error"); errCode != "" {
+		loginEvents.RecordFailure("", c.ClientIP(), c.Request.UserAgent(), models.LoginMethodOIDC, "provider_error: "+errCode)
+		redirectLoginPage(c, "oidcError", "Single sign-on was rejected: "+errCode)
+		return
+	}
+
+	user, err := oc.oidcService.HandleCallback(c.Request.Context(), c.Query("code"), c.Query("state"), stateCookie)
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		loginEvents.RecordFailure("", c.ClientIP(), c.Request.UserAgent(), models.LoginMethodOIDC, "callback_failed")
+		redirectLoginPage(c, "oidcError", "Single sign-on failed")
+		return
+	}
+	c.Set("auditUsername", user.Username)
+
+	if service.NewTwoFactorService().IsEnabled(user.Username) {
+		preAuthToken, err := signPreAuthToken(user)
+		if err != nil {
+			redirectLoginPage(c, "oidcError", "Failed to generate token")
+			return
+		}
+		redirectLoginPage(c, "preAuthToken", preAuthToken)
+		return
+	}
+
+	setupRequired := global.ConfigCacheInstance != nil && global.ConfigCacheInstance.GetTwoFactorRequired()
+	if _, err := issueAccessToken(c, user, setupRequired); err != nil {
+		redirectLoginPage(c, "oidcError", "Failed to generate token")
+		return
+	}
+	loginEvents.RecordSuccess(user, c.ClientIP(), c.Request.UserAgent(), models.LoginMethodOIDC)
+	c.Set("userID", user.ID)
+	redirectLoginPage(c, "oidc", "success")
+}
+
+// redirectLoginPage 结果放在 URL 片段中，不会发送到服务器或写入访问日志
+func redirectLoginPage(c *gin.Context, key, value string) {
+	c.Redirect(http.StatusFound, "/login#"+url.Values{key: {value}}.Encode())
+}
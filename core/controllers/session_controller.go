@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/models"
+	"gpanel/service"
+)
+
+type SessionController struct {
+	sessionService service.ISessionService
+}
+
+func NewSessionController() *SessionController {
+	return &SessionController{
+		sessionService: service.NewSessionService(),
+	}
+}
+
+// sessionView 会话列表项，标记是否为当前会话
+type sessionView struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+func toSessionViews(sessions []models.Session, currentID uint) []sessionView {
+	views := make([]sessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, sessionView{
+			Session: session,
+			Current: session.ID == currentID,
+		})
+	}
+	return views
+}
+
+// Logout 吊销当前会话并清除刷新令牌和访问 token cookie
+func (sc *SessionController) Logout(c *gin.Context) {
+	if err := sc.sessionService.RevokeByJTI(c.GetString("jti")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to logout",
+		})
+		return
+	}
+	clearRefreshCookie(c)
+	clearAccessCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out successfully",
+	})
+}
+
+// ListMySessions 当前用户的活动会话
+func (sc *SessionController) ListMySessions(c *gin.Context) {
+	sessions, err := sc.sessionService.ListActive(c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": toSessionViews(sessions, c.GetUint("sessionID")),
+	})
+}
+
+func (sc *SessionController) RevokeMySession(c *gin.Context) {
+	sc.revoke(c, false)
+}
+
+// ListSessions 所有用户的活动会话
+func (sc *SessionController) ListSessions(c *gin.Context) {
+	sessions, err := sc.sessionService.ListAllActive()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": toSessionViews(sessions, c.GetUint("sessionID")),
+	})
+}
+
+// TerminateSession 管理员终止任意会话
+func (sc *SessionController) TerminateSession(c *gin.Context) {
+	sc.revoke(c, true)
+}
+
+func (sc *SessionController) revoke(c *gin.Context, anyUser bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid session id",
+		})
+		return
+	}
+
+	if err := sc.sessionService.Revoke(uint(id), c.GetUint("userID"), anyUser); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Session not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to terminate session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session terminated successfully",
+	})
+}
@@ -1,20 +1,40 @@
 package controllers
 
 import (
+	"errors"
 	"gpanel/global"
+	"gpanel/middleware"
+	"gpanel/models"
+	"gpanel/repo"
 	"gpanel/service"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxSettingRevisionPageSize 设置修订每页最大条数
+const maxSettingRevisionPageSize = 200
+
+// maxSettingBundleSize 导入包的最大字节数
+const maxSettingBundleSize = 1 << 20
+
+// bundlePassphraseHeader 加密导出包口令的请求头，避免口令出现在 URL 和访问日志中
+const bundlePassphraseHeader = "X-Bundle-Passphrase"
+
 type SettingController struct {
-	settingService service.ISettingService
+	settingService         service.ISettingService
+	settingRevisionService service.ISettingRevisionService
+	settingBundleService   service.ISettingBundleService
 }
 
 func NewSettingController() *SettingController {
 	return &SettingController{
-		settingService: service.NewSettingService(),
+		settingService:         service.NewSettingService(),
+		settingRevisionService: service.NewSettingRevisionService(),
+		settingBundleService:   service.NewSettingBundleService(),
 	}
 }
 
@@ -27,7 +47,7 @@ func (sc *SettingController) GetAllSettings(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"settings": settings,
+		"settings": service.PublicSettings(settings),
 	})
 }
 
@@ -40,7 +60,7 @@ func (sc *SettingController) GetSettingByKey(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(http.StatusOK, setting)
+	c.JSON(http.StatusOK, service.PublicSetting(*setting))
 }
 
 func (sc *SettingController) UpdateSetting(c *gin.Context) {
@@ -56,28 +76,30 @@ func (sc *SettingController) UpdateSetting(c *gin.Context) {
 		return
 	}
 
-	if err := sc.settingService.UpdateSetting(req.Key, req.Value); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update setting",
-		})
+	updates := map[string]string{req.Key: req.Value}
+	if !requireSecurityPermission(c, service.ChangedSecuritySettings(updates)) || !validateSettingUpdates(c, updates) {
 		return
 	}
 
-	// 更新缓存
-	if global.ConfigCacheInstance != nil {
-		global.ConfigCacheInstance.Set(req.Key, req.Value)
+	auditChanges := service.SettingChanges(updates)
+	if err := sc.settingService.UpdateSetting(req.Key, req.Value, settingActor(c)); err != nil {
+		respondSettingError(c, err, "Failed to update setting")
+		return
 	}
+	c.Set("auditChanges", auditChanges)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Setting updated successfully",
+		"message":         "Setting updated successfully",
+		"restartRequired": service.RestartRequired([]string{req.Key}),
 	})
 }
 
 func (sc *SettingController) CreateSetting(c *gin.Context) {
 	var req struct {
-		Key   string `json:"key" binding:"required"`
-		Value string `json:"value" binding:"required"`
-		About string `json:"about"`
+		Key    string `json:"key" binding:"required"`
+		Value  string `json:"value" binding:"required"`
+		About  string `json:"about"`
+		Secret bool   `json:"secret"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -87,17 +109,26 @@ func (sc *SettingController) CreateSetting(c *gin.Context) {
 		return
 	}
 
-	if err := sc.settingService.CreateSetting(req.Key, req.Value, req.About); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create setting",
+	if !requireSecurityPermission(c, service.ChangedSecuritySettings(map[string]string{req.Key: req.Value})) {
+		return
+	}
+	// 设置值由 CreateSetting 按设置项定义校验，自定义设置项不在定义中
+	if err := service.ValidateAccessUpdate(map[string]string{req.Key: req.Value}, c.ClientIP(), c.Request.Host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
 
-	// 更新缓存
-	if global.ConfigCacheInstance != nil {
-		global.ConfigCacheInstance.Set(req.Key, req.Value)
+	auditChanges := service.SettingChanges(map[string]string{req.Key: req.Value})
+	if req.Secret {
+		auditChanges = service.RedactedSettingChanges(map[string]string{req.Key: req.Value})
+	}
+	if err := sc.settingService.CreateSetting(req.Key, req.Value, req.About, req.Secret, settingActor(c)); err != nil {
+		respondSettingError(c, err, "Failed to create setting")
+		return
 	}
+	c.Set("auditChanges", auditChanges)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Setting created successfully",
@@ -106,13 +137,30 @@ func (sc *SettingController) CreateSetting(c *gin.Context) {
 
 func (sc *SettingController) DeleteSetting(c *gin.Context) {
 	key := c.Param("key")
+	if service.IsSecuritySetting(key) && !requireSecurityPermission(c, []string{key}) {
+		return
+	}
 
-	if err := sc.settingService.DeleteSetting(key); err != nil {
+	auditChanges := service.SettingChanges(map[string]string{key: ""})
+	if err := sc.settingService.DeleteSetting(key, settingActor(c)); err != nil {
+		if errors.Is(err, service.ErrBuiltInSetting) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Built-in settings cannot be deleted",
+			})
+			return
+		}
+		if errors.Is(err, service.ErrSettingNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Setting not found",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to delete setting",
 		})
 		return
 	}
+	c.Set("auditChanges", auditChanges)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Setting deleted successfully",
@@ -122,7 +170,7 @@ func (sc *SettingController) DeleteSetting(c *gin.Context) {
 func (sc *SettingController) GetSystemSettings(c *gin.Context) {
 	if global.ConfigCacheInstance != nil {
 		c.JSON(http.StatusOK, gin.H{
-			"settings": global.ConfigCacheInstance.GetAll(),
+			"settings": service.PublicSettingMap(global.ConfigCacheInstance.GetAll()),
 		})
 	} else {
 		settings, err := sc.settingService.GetAllSettings()
@@ -140,7 +188,7 @@ func (sc *SettingController) GetSystemSettings(c *gin.Context) {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"settings": settingMap,
+			"settings": service.PublicSettingMap(settingMap),
 		})
 	}
 }
@@ -155,22 +203,291 @@ func (sc *SettingController) UpdateSystemSettings(c *gin.Context) {
 		return
 	}
 
-	// 批量更新设置
-	for key, value := range req {
-		if err := sc.settingService.UpdateSetting(key, value); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to update setting: " + key,
+	if !requireSecurityPermission(c, service.ChangedSecuritySettings(req)) || !validateSettingUpdates(c, req) {
+		return
+	}
+
+	// 批量更新设置，任一设置项失败则全部不生效
+	auditChanges := service.SettingChanges(req)
+	changed, err := sc.settingService.UpdateSettings(req, settingActor(c))
+	if err != nil {
+		respondSettingError(c, err, "Failed to update settings")
+		return
+	}
+	c.Set("auditChanges", auditChanges)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "System settings updated successfully",
+		"changed":         changed,
+		"restartRequired": service.RestartRequired(changed),
+	})
+}
+
+// GetSettingSchema 返回所有内置设置项的类型、默认值、取值范围和说明，供前端生成表单
+func (sc *SettingController) GetSettingSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"settings": service.SettingSchemas(),
+	})
+}
+
+// ListSettingHistory 所有设置项的修订记录，支持按设置项、批次和用户过滤
+func (sc *SettingController) ListSettingHistory(c *gin.Context) {
+	sc.respondSettingHistory(c, repo.SettingRevisionFilter{
+		Key:      c.Query("key"),
+		Batch:    c.Query("batch"),
+		Username: c.Query("username"),
+	})
+}
+
+// ListSettingKeyHistory 单个设置项的修订记录
+func (sc *SettingController) ListSettingKeyHistory(c *gin.Context) {
+	sc.respondSettingHistory(c, repo.SettingRevisionFilter{Key: c.Param("key")})
+}
+
+func (sc *SettingController) respondSettingHistory(c *gin.Context, filter repo.SettingRevisionFilter) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if pageSize < 1 || pageSize > maxSettingRevisionPageSize {
+		pageSize = 20
+	}
+
+	revisions, total, err := sc.settingRevisionService.List(filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get setting history",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revisions": service.PublicSettingRevisions(revisions),
+		"total":     total,
+		"page":      page,
+		"pageSize":  pageSize,
+	})
+}
+
+// RollbackSettings 将单条修订或整个批次的设置项恢复为修订之前的值，
+// 指定 asOf 时将所有设置项恢复为该修订提交后的状态
+func (sc *SettingController) RollbackSettings(c *gin.Context) {
+	var req struct {
+		RevisionID uint   `json:"revisionId"`
+		Batch      string `json:"batch"`
+		AsOf       uint   `json:"asOf"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || countSet(req.RevisionID != 0, req.Batch != "", req.AsOf != 0) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Exactly one of revisionId, batch or asOf is required",
+		})
+		return
+	}
+
+	var targets []models.SettingRevision
+	var err error
+	if req.AsOf != 0 {
+		targets, err = sc.settingRevisionService.RestoreTargets(req.AsOf)
+	} else {
+		targets, err = sc.settingRevisionService.RollbackTargets(req.RevisionID, req.Batch)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrNothingToRollback) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Revision not found",
 			})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get revisions",
+		})
+		return
+	}
+
+	if !requireSecurityPermission(c, service.RollbackSecuritySettings(targets)) {
+		return
+	}
+	// 旧值可能已不符合当前的设置项定义，与普通修改一样完整校验
+	if !validateSettingUpdates(c, service.RollbackUpdates(targets)) {
+		return
+	}
 
-		// 更新缓存
-		if global.ConfigCacheInstance != nil {
-			global.ConfigCacheInstance.Set(key, value)
+	auditChanges := service.SettingChanges(service.RollbackValues(targets))
+	changed, err := sc.settingRevisionService.Rollback(targets, settingActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to roll back settings",
+		})
+		return
+	}
+	c.Set("auditChanges", auditChanges)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Settings rolled back successfully",
+		"changed":         changed,
+		"restartRequired": service.RestartRequired(changed),
+	})
+}
+
+// ExportSettings 导出设置包，format 为 yaml 或 json，secrets 为 exclude（默认）或 encrypt
+func (sc *SettingController) ExportSettings(c *gin.Context) {
+	format := c.DefaultQuery("format", "yaml")
+	if format != "yaml" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported export format",
+		})
+		return
+	}
+
+	secrets := c.DefaultQuery("secrets", service.BundleSecretsExclude)
+	bundle, err := sc.settingBundleService.Export(secrets, c.GetHeader(bundlePassphraseHeader))
+	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedBundleSecrets) || errors.Is(err, service.ErrBundlePassphraseRequired) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export settings",
+		})
+		return
+	}
+	// 导出敏感设置属于敏感操作，GET 请求也记录审计日志
+	if secrets == service.BundleSecretsEncrypt {
+		c.Set("auditForce", true)
+	}
+
+	data, err := service.EncodeSettingBundle(bundle, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export settings",
+		})
+		return
+	}
+
+	contentType := "application/yaml; charset=utf-8"
+	if format == "json" {
+		contentType = "application/json; charset=utf-8"
+	}
+	filename := "gpanel-settings-" + time.Now().Format("20060102-150405") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ImportSettings 导入设置包，dryRun=true 时只返回与当前设置的差异，否则按普通修改的规则校验后在同一事务中写入
+func (sc *SettingController) ImportSettings(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSettingBundleSize+1))
+	if err != nil || len(data) > maxSettingBundleSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid settings bundle",
+		})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+	bundle, err := service.DecodeSettingBundle(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	values, err := bundle.Values(c.GetHeader(bundlePassphraseHeader))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if !requireSecurityPermission(c, service.ChangedSecuritySettings(values)) {
+		return
+	}
+	// 设置值由 Import 按设置项定义校验，导出包中的自定义设置项在本服务器上可能还不存在
+	if err := service.ValidateAccessUpdate(values, c.ClientIP(), c.Request.Host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	auditChanges := service.BundleSettingChanges(values, bundle.Custom)
+	result, err := sc.settingBundleService.Import(values, bundle.Custom, dryRun, settingActor(c))
+	if err != nil {
+		respondSettingError(c, err, "Failed to import settings")
+		return
+	}
+	if !dryRun {
+		c.Set("auditChanges", auditChanges)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "System settings updated successfully",
+		"dryRun":          result.DryRun,
+		"changes":         result.Changes,
+		"changed":         result.Changed,
+		"restartRequired": service.RestartRequired(result.Changed),
+	})
+}
+
+// settingActor 当前登录用户，记录在设置修订中
+func settingActor(c *gin.Context) service.SettingActor {
+	return service.SettingActor{
+		UserID:   c.GetUint("userID"),
+		Username: c.GetString("username"),
+	}
+}
+
+// requireSecurityPermission 修改认证和访问控制相关的设置项需要 security:manage 权限，没有权限时返回 403 并中止
+func requireSecurityPermission(c *gin.Context, keys []string) bool {
+	if len(keys) == 0 || middleware.HasPermission(c, models.PermSecurityManage) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "Permission denied",
+		"keys":  keys,
+	})
+	return false
+}
+
+// validateSettingUpdates 校验设置值和访问限制，不合法时返回 400 并中止
+func validateSettingUpdates(c *gin.Context, updates map[string]string) bool {
+	if err := service.ValidateSettingUpdates(updates); err != nil {
+		respondSettingError(c, err, "Invalid settings")
+		return false
+	}
+	if err := service.ValidateAccessUpdate(updates, c.ClientIP(), c.Request.Host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// countSet 统计为 true 的条件个数，用于校验互斥的请求参数
+func countSet(conditions ...bool) int {
+	n := 0
+	for _, set := range conditions {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// respondSettingError 校验错误返回 400 及各设置项的错误信息，其他错误返回 500
+func respondSettingError(c *gin.Context, err error, message string) {
+	var validationErr *service.SettingValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Invalid settings",
+			"fields": validationErr.Fields,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": message,
 	})
-}
\ No newline at end of file
+}
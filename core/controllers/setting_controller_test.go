@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/repo"
+	"gpanel/service"
+)
+
+// setupSettingTestDB 在临时目录中创建设置表、修订表和配置缓存，并写入内置设置的默认值
+func setupSettingTestDB(t *testing.T) {
+	t.Helper()
+
+	global.DataDir = t.TempDir()
+	if err := global.InitDB(); err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	t.Cleanup(global.CloseDB)
+	if err := global.InitMasterKey(); err != nil {
+		t.Fatalf("init master key: %v", err)
+	}
+	if err := global.DB.AutoMigrate(&models.Setting{}, &models.SettingRevision{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := service.NewSettingService().InitializeDefaultSettings(); err != nil {
+		t.Fatalf("default settings: %v", err)
+	}
+	if err := global.InitConfigCache(); err != nil {
+		t.Fatalf("init config cache: %v", err)
+	}
+	t.Cleanup(func() { global.ConfigCacheInstance = nil })
+}
+
+// newSettingTestRouter X-Test-Role 模拟已登录用户的角色，X-Test-Scopes 模拟个人访问令牌的 scope
+func newSettingTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("username", "tester")
+		c.Set("role", c.GetHeader("X-Test-Role"))
+		if scopes := c.GetHeader("X-Test-Scopes"); scopes != "" {
+			c.Set("scopes", strings.Split(scopes, ","))
+		}
+	})
+
+	settingController := NewSettingController()
+	router.POST("/api/v1/config", UpdateConfig)
+	router.POST("/api/v1/settings/system", settingController.UpdateSystemSettings)
+	router.POST("/api/v1/settings/import", settingController.ImportSettings)
+	router.POST("/api/v1/settings/rollback", settingController.RollbackSettings)
+	router.POST("/api/v1/settings", settingController.CreateSetting)
+	router.PUT("/api/v1/settings", settingController.UpdateSetting)
+	router.DELETE("/api/v1/settings/:key", settingController.DeleteSetting)
+	return router
+}
+
+func TestSecuritySettingsRequireSecurityPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		scopes     string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"operator changes IP allow list", models.RoleOperator, "", http.MethodPost, "/api/v1/settings/system", `{"IPAllowList": "192.0.2.0/24"}`, http.StatusForbidden},
+		{"operator changes OIDC issuer", models.RoleOperator, "", http.MethodPut, "/api/v1/settings", `{"key": "OIDCIssuer", "value": "https://idp.example.com"}`, http.StatusForbidden},
+		{"operator changes forced 2FA via config", models.RoleOperator, "", http.MethodPost, "/api/v1/config", `{"TwoFactorRequired": "true"}`, http.StatusForbidden},
+		{"operator changes lockout threshold", models.RoleOperator, "", http.MethodPost, "/api/v1/settings/system", `{"Language": "en-US", "LoginMaxAttempts": "100"}`, http.StatusForbidden},
+		{"operator changes LDAP bind password", models.RoleOperator, "", http.MethodPost, "/api/v1/settings/system", `{"LDAPBindPassword": "new-secret"}`, http.StatusForbidden},
+		{"operator changes the panel password", models.RoleOperator, "", http.MethodPost, "/api/v1/settings/system", `{"PanelPassword": "New-Secret-123"}`, http.StatusForbidden},
+		{"operator creates a security setting", models.RoleOperator, "", http.MethodPost, "/api/v1/settings", `{"key": "SecurityEntrance", "value": "/other"}`, http.StatusForbidden},
+		{"operator deletes a security setting", models.RoleOperator, "", http.MethodDelete, "/api/v1/settings/OIDCClientSecret", "", http.StatusForbidden},
+		{"operator imports security settings", models.RoleOperator, "", http.MethodPost, "/api/v1/settings/import", "version: 1\nsettings:\n  LDAPURL: ldap://evil.example.com\n", http.StatusForbidden},
+		{"admin token without security scope", models.RoleAdmin, models.PermSettingsWrite, http.MethodPost, "/api/v1/settings/system", `{"IPDenyList": "198.51.100.1"}`, http.StatusForbidden},
+		{"operator changes general settings", models.RoleOperator, "", http.MethodPost, "/api/v1/settings/system", `{"Language": "en-US"}`, http.StatusOK},
+		{"operator resubmits unchanged security settings", models.RoleOperator, "", http.MethodPost, "/api/v1/settings/system", `{"Language": "en-US", "LoginMaxAttempts": "5", "LDAPBindPassword": "********"}`, http.StatusOK},
+		{"delete missing setting", models.RoleAdmin, "", http.MethodDelete, "/api/v1/settings/MissingNote", "", http.StatusNotFound},
+		{"admin changes IP allow list", models.RoleAdmin, "", http.MethodPost, "/api/v1/settings/system", `{"IPAllowList": "192.0.2.0/24"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSettingTestDB(t)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("X-Test-Role", tt.role)
+			req.Header.Set("X-Test-Scopes", tt.scopes)
+			w := httptest.NewRecorder()
+			newSettingTestRouter().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSecuritySettingRollbackRequiresSecurityPermission(t *testing.T) {
+	setupSettingTestDB(t)
+	if _, err := service.NewSettingService().UpdateSettings(map[string]string{"IPDenyList": "198.51.100.1"}, service.SettingActor{Username: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	revisions, _, err := service.NewSettingRevisionService().List(repo.SettingRevisionFilter{Key: "IPDenyList"}, 1, 1)
+	if err != nil || len(revisions) != 1 {
+		t.Fatalf("revisions = %v, %v", revisions, err)
+	}
+	body := `{"batch": "` + revisions[0].Batch + `"}`
+
+	for _, tt := range []struct {
+		role       string
+		wantStatus int
+	}{
+		{models.RoleOperator, http.StatusForbidden},
+		{models.RoleAdmin, http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/settings/rollback", strings.NewReader(body))
+		req.Header.Set("X-Test-Role", tt.role)
+		w := httptest.NewRecorder()
+		newSettingTestRouter().ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", tt.role, w.Code, tt.wantStatus, w.Body.String())
+		}
+	}
+}
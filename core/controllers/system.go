@@ -47,7 +47,7 @@ func GetCurrentInfo(c *gin.Context) {
 
 func GetConfig(c *gin.Context) {
 	if global.ConfigCacheInstance != nil {
-		config := global.ConfigCacheInstance.GetAll()
+		config := service.PublicSettingMap(global.ConfigCacheInstance.GetAll())
 		c.JSON(http.StatusOK, config)
 	} else {
 		settingService := service.NewSettingService()
@@ -64,7 +64,7 @@ func GetConfig(c *gin.Context) {
 			configMap[setting.Key] = setting.Value
 		}
 
-		c.JSON(http.StatusOK, configMap)
+		c.JSON(http.StatusOK, service.PublicSettingMap(configMap))
 	}
 }
 
@@ -77,23 +77,22 @@ func UpdateConfig(c *gin.Context) {
 		return
 	}
 
-	settingService := service.NewSettingService()
-	for key, value := range updates {
-		if err := settingService.UpdateSetting(key, value); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to update config: " + key,
-			})
-			return
-		}
+	if !requireSecurityPermission(c, service.ChangedSecuritySettings(updates)) || !validateSettingUpdates(c, updates) {
+		return
+	}
 
-		// 更新缓存
-		if global.ConfigCacheInstance != nil {
-			global.ConfigCacheInstance.Set(key, value)
-		}
+	auditChanges := service.SettingChanges(updates)
+	changed, err := service.NewSettingService().UpdateSettings(updates, settingActor(c))
+	if err != nil {
+		respondSettingError(c, err, "Failed to update config")
+		return
 	}
+	c.Set("auditChanges", auditChanges)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Config updated successfully",
+		"message":         "Config updated successfully",
+		"changed":         changed,
+		"restartRequired": service.RestartRequired(changed),
 	})
 }
 
@@ -124,6 +123,14 @@ func ReloadConfig(c *gin.Context) {
 	})
 }
 
+// GetConfigSources 返回配置文件支持的各配置项的生效值及来源（env、file、database、default）
+func GetConfigSources(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"configFile": global.ConfigFileUsed,
+		"sources":    global.ConfigSources(),
+	})
+}
+
 func GetVersion(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"version": "v0.0.1",
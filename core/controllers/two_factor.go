@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+	"gpanel/service"
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func twoFactorRequired() bool {
+	return global.ConfigCacheInstance != nil && global.ConfigCacheInstance.GetTwoFactorRequired()
+}
+
+func GetTwoFactorStatus(c *gin.Context) {
+	username := c.GetString("username")
+	status := service.NewTwoFactorService().GetStatus(username, twoFactorRequired())
+	c.JSON(http.StatusOK, status)
+}
+
+// SetupTwoFactor 生成 TOTP 密钥、otpauth URI 及二维码
+func SetupTwoFactor(c *gin.Context) {
+	username := c.GetString("username")
+	setup, err := service.NewTwoFactorService().Setup(username)
+	if err != nil {
+		if errors.Is(err, service.ErrTwoFactorAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Two-factor authentication is already enabled",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to set up two-factor authentication",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// EnableTwoFactor 校验验证码并启用双因素认证，恢复码仅在此返回一次
+func EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	username := c.GetString("username")
+	var codes []string
+	if !runThrottledTwoFactorCheck(c, func() (err error) {
+		codes, err = service.NewTwoFactorService().Enable(username, req.Code)
+		return err
+	}) {
+		return
+	}
+
+	response := gin.H{
+		"message":       "Two-factor authentication enabled successfully",
+		"recoveryCodes": codes,
+	}
+
+	// 强制绑定流程中使用的是受限 token，绑定完成后换发正常 token
+	if c.GetBool("twoFactorSetup") {
+		user, err := service.NewUserService().GetByUsername(username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate token",
+			})
+			return
+		}
+		token, err := issueAccessToken(c, user, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate token",
+			})
+			return
+		}
+		if err := putAccessToken(c, response, token); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate token",
+			})
+			return
+		}
+
+		// 受限会话不再需要
+		_ = service.NewSessionService().RevokeByJTI(c.GetString("jti"))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	if twoFactorRequired() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Two-factor authentication is mandatory",
+		})
+		return
+	}
+
+	if !runThrottledTwoFactorCheck(c, func() error {
+		return service.NewTwoFactorService().Disable(c.GetString("username"), req.Code)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication disabled successfully",
+	})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	var codes []string
+	if !runThrottledTwoFactorCheck(c, func() (err error) {
+		codes, err = service.NewTwoFactorService().RegenerateRecoveryCodes(c.GetString("username"), req.Code)
+		return err
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recoveryCodes": codes,
+	})
+}
+
+// runThrottledTwoFactorCheck 执行需要验证码的操作，与 LoginTwoFactor 共用失败计数，防止暴力猜测验证码
+func runThrottledTwoFactorCheck(c *gin.Context, action func() error) bool {
+	username := c.GetString("username")
+	throttle := service.NewLoginThrottleService()
+	if retryAfter, blocked := throttle.Reserve(c.ClientIP(), username); blocked {
+		respondTooManyAttempts(c, retryAfter)
+		return false
+	}
+
+	err := action()
+	switch {
+	case err == nil:
+		throttle.RecordSuccess(c.ClientIP(), username)
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		throttle.RecordFailure(c.ClientIP(), username)
+	default:
+		throttle.Release(c.ClientIP(), username)
+	}
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return false
+	}
+	return true
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid verification code",
+		})
+	case errors.Is(err, service.ErrTwoFactorNotSetup), errors.Is(err, service.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Two-factor authentication is not set up",
+		})
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Two-factor authentication is already enabled",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update two-factor authentication",
+		})
+	}
+}
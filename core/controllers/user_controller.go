@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/service"
+)
+
+type UserController struct {
+	userService service.IUserService
+}
+
+func NewUserController() *UserController {
+	return &UserController{
+		userService: service.NewUserService(),
+	}
+}
+
+func (uc *UserController) ListUsers(c *gin.Context) {
+	users, err := uc.userService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get users",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+	})
+}
+
+func (uc *UserController) GetUser(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := uc.userService.GetByID(id)
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (uc *UserController) CreateUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	user, err := uc.userService.Create(req.Username, req.Password, req.Role)
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (uc *UserController) UpdateUser(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.UserUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	user, err := uc.userService.Update(id, req)
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (uc *UserController) DeleteUser(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	if id == c.GetUint("userID") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Cannot delete the current user",
+		})
+		return
+	}
+
+	if err := uc.userService.Delete(id); err != nil {
+		respondUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User deleted successfully",
+	})
+}
+
+func parseUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user id",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+	case errors.Is(err, service.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Username already exists",
+		})
+	case errors.Is(err, service.ErrInvalidRole),
+		errors.Is(err, service.ErrInvalidUsername),
+		errors.Is(err, service.ErrInvalidPassword),
+		errors.Is(err, service.ErrWeakPassword),
+		errors.Is(err, service.ErrIncorrectPassword),
+		errors.Is(err, service.ErrPasswordReused),
+		errors.Is(err, service.ErrExternalUser),
+		errors.Is(err, service.ErrLastAdmin):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update user",
+		})
+	}
+}
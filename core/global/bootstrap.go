@@ -0,0 +1,189 @@
+package global
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// configFileEnv 指定配置文件路径的环境变量，未设置时读取工作目录下的 config.yaml（不存在则忽略）
+const configFileEnv = "GPANEL_CONFIG"
+
+// envPrefix 覆盖配置文件的环境变量前缀，如 server.port 对应 GPANEL_SERVER_PORT
+const envPrefix = "GPANEL"
+
+// 配置来源
+const (
+	ConfigSourceDefault  = "default"
+	ConfigSourceDatabase = "database"
+	ConfigSourceFile     = "file"
+	ConfigSourceEnv      = "env"
+)
+
+// bootstrapKey 配置文件中的一项，Setting 不为空时该项会覆盖同名的数据库设置
+type bootstrapKey struct {
+	Key     string
+	Setting string
+	Default string
+}
+
+// bootstrapKeys 配置文件支持的全部配置项。
+// 优先级：环境变量 > 配置文件 > 数据库设置 > 默认值。数据目录和日志只能通过环境变量或配置文件设置，在打开数据库之前确定
+var bootstrapKeys = []bootstrapKey{
+	{Key: "server.port", Setting: "ServerPort", Default: "8080"},
+	{Key: "server.mode", Setting: "ServerMode", Default: "debug"},
+	{Key: "server.listen_address", Setting: "ListenAddress", Default: "0.0.0.0"},
+	{Key: "database.data_dir", Default: filepath.Join(".", "data")},
+	{Key: "logging.level", Default: "info"},
+	{Key: "logging.file", Default: ""},
+}
+
+// ConfigSource 配置项的生效值及其来源
+type ConfigSource struct {
+	Key     string `json:"key"`
+	Setting string `json:"setting,omitempty"`
+	Env     string `json:"env"`
+	Value   string `json:"value"`
+	Source  string `json:"source"`
+}
+
+var (
+	// ConfigFileUsed 实际读取的配置文件，未读取时为空
+	ConfigFileUsed string
+	// LogLevel 日志级别：debug 记录请求和所有 SQL 语句，info 记录请求、慢查询和数据库错误，
+	// warn 不记录请求，error 只记录数据库错误。启动信息和警告等应用日志不受影响
+	LogLevel = "info"
+
+	bootstrapValues = make(map[string]ConfigSource)
+	// configOverrides 由配置文件或环境变量覆盖的数据库设置，启动后不再修改
+	configOverrides = make(map[string]string)
+)
+
+// LoadBootstrapConfig 读取配置文件和 GPANEL_* 环境变量，需在打开数据库之前调用
+func LoadBootstrapConfig() error {
+	// 环境变量按 envName 逐项读取，viper 只负责解析配置文件
+	v := viper.New()
+	bootstrapValues = make(map[string]ConfigSource)
+	configOverrides = make(map[string]string)
+
+	path, explicit := os.LookupEnv(configFileEnv)
+	if !explicit {
+		path = "config.yaml"
+	}
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		if explicit || !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+	} else {
+		ConfigFileUsed = v.ConfigFileUsed()
+	}
+
+	for _, item := range bootstrapKeys {
+		source := ConfigSource{
+			Key:     item.Key,
+			Setting: item.Setting,
+			Env:     envName(item.Key),
+			Value:   item.Default,
+			Source:  ConfigSourceDefault,
+		}
+		if value, ok := os.LookupEnv(source.Env); ok && value != "" {
+			source.Value, source.Source = value, ConfigSourceEnv
+		} else if v.InConfig(item.Key) {
+			source.Value, source.Source = v.GetString(item.Key), ConfigSourceFile
+		}
+
+		bootstrapValues[item.Key] = source
+		if item.Setting != "" && source.Source != ConfigSourceDefault {
+			configOverrides[item.Setting] = source.Value
+		}
+	}
+
+	DataDir = bootstrapValues["database.data_dir"].Value
+	LogLevel = strings.ToLower(bootstrapValues["logging.level"].Value)
+	switch LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("invalid logging.level %q, expected debug, info, warn or error", LogLevel)
+	}
+	return nil
+}
+
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ConfigOverride 返回覆盖数据库设置 setting 的配置项，未被覆盖时返回 false
+func ConfigOverride(setting string) (ConfigSource, bool) {
+	if _, ok := configOverrides[setting]; !ok {
+		return ConfigSource{}, false
+	}
+	for _, item := range bootstrapKeys {
+		if item.Setting == setting {
+			return bootstrapValues[item.Key], true
+		}
+	}
+	return ConfigSource{}, false
+}
+
+// RequestLogEnabled 日志级别为 debug 或 info 时记录 HTTP 请求日志
+func RequestLogEnabled() bool {
+	return LogLevel == "debug" || LogLevel == "info"
+}
+
+// ConfigOverrides 返回由配置文件或环境变量覆盖的数据库设置
+func ConfigOverrides() map[string]string {
+	result := make(map[string]string, len(configOverrides))
+	for k, v := range configOverrides {
+		result[k] = v
+	}
+	return result
+}
+
+// ConfigSources 返回各配置项的生效值和来源，未被覆盖的数据库设置来源为 database
+func ConfigSources() []ConfigSource {
+	sources := make([]ConfigSource, 0, len(bootstrapKeys))
+	for _, item := range bootstrapKeys {
+		source, ok := bootstrapValues[item.Key]
+		if !ok {
+			source = ConfigSource{Key: item.Key, Setting: item.Setting, Env: envName(item.Key), Value: item.Default, Source: ConfigSourceDefault}
+		}
+		if item.Setting != "" && source.Source == ConfigSourceDefault && ConfigCacheInstance != nil {
+			if value, exists := ConfigCacheInstance.Get(item.Setting); exists {
+				source.Value, source.Source = value, ConfigSourceDatabase
+			}
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// LogWriter 日志输出，配置了 logging.file 时同时写入文件
+var LogWriter io.Writer = os.Stdout
+
+// InitLogging 按 logging.file 配置日志输出，标准库日志与 gin、数据库日志统一写入 LogWriter，
+// 文件无法打开时继续输出到标准输出
+func InitLogging() {
+	log.SetOutput(LogWriter)
+	file := bootstrapValues["logging.file"].Value
+	if file == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		log.Printf("Warning: Failed to create log directory: %v", err)
+		return
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		log.Printf("Warning: Failed to open log file: %v", err)
+		return
+	}
+	LogWriter = io.MultiWriter(LogWriter, f)
+	log.SetOutput(LogWriter)
+}
@@ -3,6 +3,7 @@ package global
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -10,6 +11,7 @@ import (
 type ConfigCache struct {
 	mu         sync.RWMutex
 	settings   map[string]string
+	secrets    map[string]bool
 	version    int64
 	versionStr string
 }
@@ -23,21 +25,47 @@ func InitConfigCache() error {
 	}
 	ConfigCacheInstance.versionStr = fmt.Sprintf("%d", ConfigCacheInstance.version)
 
-	settingRepo := NewSettingRepo()
-	settings, err := settingRepo.List()
+	settings, secrets, err := loadSettings()
 	if err != nil {
 		return err
 	}
-
-	for _, setting := range settings {
-		ConfigCacheInstance.settings[setting.Key] = setting.Value
-	}
+	ConfigCacheInstance.settings = settings
+	ConfigCacheInstance.secrets = secrets
 
 	log.Printf("Config cache initialized with %d settings, version: %s", len(ConfigCacheInstance.settings), ConfigCacheInstance.versionStr)
 	return nil
 }
 
+// loadSettings 从数据库读取全部设置，敏感设置解密后放入缓存，解密失败时置空
+func loadSettings() (map[string]string, map[string]bool, error) {
+	settingRepo := NewSettingRepo()
+	list, err := settingRepo.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	settings := make(map[string]string, len(list))
+	secrets := make(map[string]bool)
+	for _, setting := range list {
+		if setting.Secret {
+			secrets[setting.Key] = true
+			value, err := DecryptSecret(setting.Value)
+			if err != nil {
+				log.Printf("Failed to decrypt setting %s: %v", setting.Key, err)
+			}
+			settings[setting.Key] = value
+			continue
+		}
+		settings[setting.Key] = setting.Value
+	}
+	return settings, secrets, nil
+}
+
+// Get 返回设置的生效值，配置文件或环境变量覆盖的设置优先于数据库
 func (cc *ConfigCache) Get(key string) (string, bool) {
+	if value, ok := configOverrides[key]; ok {
+		return value, true
+	}
 	cc.mu.RLock()
 	defer cc.mu.RUnlock()
 	value, exists := cc.settings[key]
@@ -50,6 +78,48 @@ func (cc *ConfigCache) Set(key, value string) {
 	cc.settings[key] = value
 }
 
+// SetSecret 写入敏感设置的明文值并标记为敏感
+func (cc *ConfigCache) SetSecret(key, value string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.settings[key] = value
+	cc.secrets[key] = true
+}
+
+// SetBatch 一次性替换多个设置项并移除 removed 中的设置项：先复制出新的设置表再整体替换，读取方不会看到只写入一部分的配置
+func (cc *ConfigCache) SetBatch(values map[string]string, secrets map[string]bool, removed []string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	settings := make(map[string]string, len(cc.settings)+len(values))
+	for k, v := range cc.settings {
+		settings[k] = v
+	}
+	secretKeys := make(map[string]bool, len(cc.secrets))
+	for k := range cc.secrets {
+		secretKeys[k] = true
+	}
+	for _, k := range removed {
+		delete(settings, k)
+		delete(secretKeys, k)
+	}
+	for k, v := range values {
+		settings[k] = v
+		if secrets[k] {
+			secretKeys[k] = true
+		}
+	}
+	cc.settings = settings
+	cc.secrets = secretKeys
+}
+
+// IsSecret 判断设置项是否为敏感设置
+func (cc *ConfigCache) IsSecret(key string) bool {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.secrets[key]
+}
+
 func (cc *ConfigCache) GetAll() map[string]string {
 	cc.mu.RLock()
 	defer cc.mu.RUnlock()
@@ -57,6 +127,9 @@ func (cc *ConfigCache) GetAll() map[string]string {
 	for k, v := range cc.settings {
 		result[k] = v
 	}
+	for k, v := range configOverrides {
+		result[k] = v
+	}
 	return result
 }
 
@@ -64,16 +137,12 @@ func (cc *ConfigCache) Reload() error {
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
 
-	settingRepo := NewSettingRepo()
-	settings, err := settingRepo.List()
+	settings, secrets, err := loadSettings()
 	if err != nil {
 		return err
 	}
-
-	cc.settings = make(map[string]string)
-	for _, setting := range settings {
-		cc.settings[setting.Key] = setting.Value
-	}
+	cc.settings = settings
+	cc.secrets = secrets
 
 	cc.version = time.Now().Unix()
 	cc.versionStr = fmt.Sprintf("%d", cc.version)
@@ -86,8 +155,16 @@ func (cc *ConfigCache) UpdateSetting(key, value string) error {
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
 
+	stored := value
+	if cc.secrets[key] {
+		var err error
+		if stored, err = EncryptSecret(value); err != nil {
+			return err
+		}
+	}
+
 	settingRepo := NewSettingRepo()
-	if err := settingRepo.Update(key, value); err != nil {
+	if err := settingRepo.Update(key, stored); err != nil {
 		return err
 	}
 
@@ -141,20 +218,6 @@ func (cc *ConfigCache) IsInitialized() bool {
 	return exists && initialized == "true"
 }
 
-func (cc *ConfigCache) GetPanelUser() string {
-	if user, exists := cc.Get("PanelUser"); exists {
-		return user
-	}
-	return "admin"
-}
-
-func (cc *ConfigCache) GetPanelPassword() string {
-	if password, exists := cc.Get("PanelPassword"); exists {
-		return password
-	}
-	return "admin123"
-}
-
 func (cc *ConfigCache) GetSessionTimeout() int {
 	if timeout, exists := cc.Get("SessionTimeout"); exists {
 		if timeout == "" {
@@ -167,6 +230,16 @@ func (cc *ConfigCache) GetSessionTimeout() int {
 	return 86400
 }
 
+// GetAccessTokenTTL 访问 token 有效期（秒），过期后通过刷新令牌换发
+func (cc *ConfigCache) GetAccessTokenTTL() int {
+	return cc.getInt("AccessTokenTTL", 900)
+}
+
+// GetSessionIdleTimeout 会话空闲超时（秒），每次刷新顺延，不超过 SessionTimeout
+func (cc *ConfigCache) GetSessionIdleTimeout() int {
+	return cc.getInt("SessionIdleTimeout", 7200)
+}
+
 func (cc *ConfigCache) GetServerAddress() string {
 	if address, exists := cc.Get("ServerAddress"); exists {
 		return address
@@ -174,6 +247,14 @@ func (cc *ConfigCache) GetServerAddress() string {
 	return ""
 }
 
+// GetBindDomain 是否只允许通过 ServerAddress 中的域名访问面板
+func (cc *ConfigCache) GetBindDomain() bool {
+	if bind, exists := cc.Get("BindDomain"); exists {
+		return bind == "true"
+	}
+	return false
+}
+
 func (cc *ConfigCache) GetListenAddress() string {
 	if address, exists := cc.Get("ListenAddress"); exists {
 		return address
@@ -188,6 +269,260 @@ func (cc *ConfigCache) GetPasswordComplexityCheck() bool {
 	return false
 }
 
+// GetPasswordMinLength 密码最小长度
+func (cc *ConfigCache) GetPasswordMinLength() int {
+	return cc.getInt("PasswordMinLength", 8)
+}
+
+// GetPasswordMaxAge 密码最长使用天数，0 表示不过期
+func (cc *ConfigCache) GetPasswordMaxAge() int {
+	return cc.getInt("PasswordMaxAge", 0)
+}
+
+// getInt 读取整数配置，不存在或格式错误时返回默认值
+func (cc *ConfigCache) getInt(key string, defaultValue int) int {
+	value, exists := cc.Get(key)
+	if !exists {
+		return defaultValue
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+// GetLoginMaxAttempts 触发锁定前允许的连续登录失败次数
+func (cc *ConfigCache) GetLoginMaxAttempts() int {
+	return cc.getInt("LoginMaxAttempts", 5)
+}
+
+// GetLoginLockoutDuration 锁定时长（秒），重复触发时按指数增长
+func (cc *ConfigCache) GetLoginLockoutDuration() int {
+	return cc.getInt("LoginLockoutDuration", 900)
+}
+
+// GetLoginBackoffBase 失败后等待时间的基数（秒），每次失败翻倍
+func (cc *ConfigCache) GetLoginBackoffBase() int {
+	return cc.getInt("LoginBackoffBase", 1)
+}
+
+// GetLoginAttemptWindow 失败次数统计窗口（秒），超过窗口未失败则重新计数
+func (cc *ConfigCache) GetLoginAttemptWindow() int {
+	return cc.getInt("LoginAttemptWindow", 3600)
+}
+
+func (cc *ConfigCache) GetIPAllowList() string {
+	value, _ := cc.Get("IPAllowList")
+	return value
+}
+
+func (cc *ConfigCache) GetIPDenyList() string {
+	value, _ := cc.Get("IPDenyList")
+	return value
+}
+
+func (cc *ConfigCache) GetTrustedProxies() string {
+	if proxies, exists := cc.Get("TrustedProxies"); exists {
+		return proxies
+	}
+	return "127.0.0.1,::1"
+}
+
+// GetEntranceSessionTimeout 安全入口 sessionkey 有效期（秒）
+func (cc *ConfigCache) GetEntranceSessionTimeout() int {
+	return cc.getInt("EntranceSessionTimeout", 1800)
+}
+
+func (cc *ConfigCache) GetEntranceBindIP() bool {
+	if bind, exists := cc.Get("EntranceBindIP"); exists {
+		return bind == "true"
+	}
+	return false
+}
+
+func (cc *ConfigCache) GetEntranceBindUserAgent() bool {
+	if bind, exists := cc.Get("EntranceBindUserAgent"); exists {
+		return bind == "true"
+	}
+	return false
+}
+
+// GetEntranceProtectAPI API 路由是否也需要经过安全入口
+func (cc *ConfigCache) GetEntranceProtectAPI() bool {
+	if protect, exists := cc.Get("EntranceProtectAPI"); exists {
+		return protect == "true"
+	}
+	return false
+}
+
+// GetPublicHealthEndpoint 开启 API 保护后仍允许公开访问的健康检查路径，为空表示不公开
+func (cc *ConfigCache) GetPublicHealthEndpoint() string {
+	value, _ := cc.Get("PublicHealthEndpoint")
+	return value
+}
+
+// GetAuthMode 登录方式：token 或 cookie
+func (cc *ConfigCache) GetAuthMode() string {
+	if mode, exists := cc.Get("AuthMode"); exists && mode != "" {
+		return mode
+	}
+	return "token"
+}
+
+// GetUnauthResponseMode 未通过安全入口、域名或 IP 校验时的响应方式
+func (cc *ConfigCache) GetUnauthResponseMode() string {
+	if mode, exists := cc.Get("UnauthResponseMode"); exists && mode != "" {
+		return mode
+	}
+	return "page"
+}
+
+func (cc *ConfigCache) GetUnauthCustomPage() string {
+	value, _ := cc.Get("UnauthCustomPage")
+	return value
+}
+
+// GetAuditRetentionDays 审计日志保留天数，0 表示永久保留
+func (cc *ConfigCache) GetAuditRetentionDays() int {
+	return cc.getInt("AuditRetentionDays", 90)
+}
+
+// GetLoginNotify 登录通知：new 仅通知新位置或新设备登录，all 通知所有成功登录，off 不通知
+func (cc *ConfigCache) GetLoginNotify() string {
+	if mode, exists := cc.Get("LoginNotify"); exists && mode != "" {
+		return mode
+	}
+	return "new"
+}
+
+func (cc *ConfigCache) GetNotifyWebhookURL() string {
+	value, _ := cc.Get("NotifyWebhookURL")
+	return value
+}
+
+// GetNotifyEmail 接收通知的邮箱，多个用逗号分隔
+func (cc *ConfigCache) GetNotifyEmail() string {
+	value, _ := cc.Get("NotifyEmail")
+	return value
+}
+
+// GetLoginHistoryRetentionDays 登录记录保留天数，0 表示永久保留
+func (cc *ConfigCache) GetLoginHistoryRetentionDays() int {
+	return cc.getInt("LoginHistoryRetentionDays", 90)
+}
+
+// SMTPConfig 发送通知邮件的 SMTP 配置
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (cc *ConfigCache) GetSMTPConfig() SMTPConfig {
+	config := SMTPConfig{Port: "587"}
+	config.Host, _ = cc.Get("SMTPHost")
+	if port, exists := cc.Get("SMTPPort"); exists && port != "" {
+		config.Port = port
+	}
+	config.Username, _ = cc.Get("SMTPUsername")
+	config.Password, _ = cc.Get("SMTPPassword")
+	config.From, _ = cc.Get("SMTPFrom")
+	return config
+}
+
+// LDAPConfig LDAP 认证配置
+type LDAPConfig struct {
+	Enabled            bool
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	GroupAttribute     string
+	RoleMapping        string
+	DefaultRole        string
+	Timeout            int
+}
+
+func (cc *ConfigCache) GetLDAPConfig() LDAPConfig {
+	all := cc.GetAll()
+	config := LDAPConfig{
+		Enabled:            all["LDAPEnabled"] == "true",
+		URL:                all["LDAPURL"],
+		StartTLS:           all["LDAPStartTLS"] == "true",
+		InsecureSkipVerify: all["LDAPInsecureSkipVerify"] == "true",
+		BindDN:             all["LDAPBindDN"],
+		BindPassword:       all["LDAPBindPassword"],
+		BaseDN:             all["LDAPBaseDN"],
+		UserFilter:         all["LDAPUserFilter"],
+		GroupAttribute:     all["LDAPGroupAttribute"],
+		RoleMapping:        all["LDAPRoleMapping"],
+		DefaultRole:        all["LDAPDefaultRole"],
+		Timeout:            cc.getInt("LDAPTimeout", 5),
+	}
+	if config.UserFilter == "" {
+		config.UserFilter = "(uid=%s)"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	return config
+}
+
+// OIDCConfig OpenID Connect 单点登录配置
+type OIDCConfig struct {
+	Enabled       bool
+	ProviderName  string
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        string
+	UsernameClaim string
+	RoleClaim     string
+	RoleMapping   string
+	DefaultRole   string
+}
+
+func (cc *ConfigCache) GetOIDCConfig() OIDCConfig {
+	all := cc.GetAll()
+	config := OIDCConfig{
+		Enabled:       all["OIDCEnabled"] == "true",
+		ProviderName:  all["OIDCProviderName"],
+		Issuer:        all["OIDCIssuer"],
+		ClientID:      all["OIDCClientID"],
+		ClientSecret:  all["OIDCClientSecret"],
+		RedirectURL:   all["OIDCRedirectURL"],
+		Scopes:        all["OIDCScopes"],
+		UsernameClaim: all["OIDCUsernameClaim"],
+		RoleClaim:     all["OIDCRoleClaim"],
+		RoleMapping:   all["OIDCRoleMapping"],
+		DefaultRole:   all["OIDCDefaultRole"],
+	}
+	if config.ProviderName == "" {
+		config.ProviderName = "SSO"
+	}
+	if config.Scopes == "" {
+		config.Scopes = "openid profile email"
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+	return config
+}
+
+func (cc *ConfigCache) GetTwoFactorRequired() bool {
+	if required, exists := cc.Get("TwoFactorRequired"); exists {
+		return required == "true"
+	}
+	return false
+}
+
 // NewSettingRepo 创建 SettingRepo 实例（避免循环导入）
 func NewSettingRepo() interface {
 	List() ([]Setting, error)
@@ -198,8 +533,9 @@ func NewSettingRepo() interface {
 
 // Setting 简化的 Setting 结构（避免循环导入）
 type Setting struct {
-	Key   string
-	Value string
+	Key    string
+	Value  string
+	Secret bool
 }
 
 // SettingRepo 简化的 SettingRepo（避免循环导入）
@@ -207,7 +543,7 @@ type SettingRepo struct{}
 
 func (r *SettingRepo) List() ([]Setting, error) {
 	var settings []Setting
-	err := DB.Table("settings").Select("key, value").Find(&settings).Error
+	err := DB.Table("settings").Select("key, value, secret").Find(&settings).Error
 	return settings, err
 }
 
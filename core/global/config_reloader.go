@@ -72,14 +72,24 @@ func (cr *ConfigReloader) reload() error {
 		return err
 	}
 
-	for _, callback := range cr.onReload {
+	cr.notify()
+
+	log.Printf("Config reloaded successfully")
+	return nil
+}
+
+// notify 执行所有配置变更回调
+func (cr *ConfigReloader) notify() {
+	cr.mu.Lock()
+	callbacks := make([]func(), len(cr.onReload))
+	copy(callbacks, cr.onReload)
+	cr.mu.Unlock()
+
+	for _, callback := range callbacks {
 		if callback != nil {
 			callback()
 		}
 	}
-
-	log.Printf("Config reloaded successfully")
-	return nil
 }
 
 func (cr *ConfigReloader) ReloadNow() error {
@@ -97,4 +107,11 @@ func ReloadConfig() error {
 		return ConfigReloaderInstance.reload()
 	}
 	return ConfigCacheInstance.Reload()
-}
\ No newline at end of file
+}
+
+// NotifyConfigChanged 单个设置写入缓存后通知回调，使依赖配置的组件及时刷新
+func NotifyConfigChanged() {
+	if ConfigReloaderInstance != nil {
+		ConfigReloaderInstance.notify()
+	}
+}
@@ -13,10 +13,18 @@ import (
 
 var DB *gorm.DB
 
+// DataDir 数据目录，存放数据库及密钥等文件，可通过 database.data_dir 配置
+var DataDir = filepath.Join(".", "data")
+
+// DBPath 数据目录中的数据库文件路径
+func DBPath() string {
+	return filepath.Join(DataDir, "gpanel.db")
+}
+
 func InitDB() error {
 	// 设置数据库文件路径
-	dbDir := filepath.Join(".", "data")
-	dbPath := filepath.Join(dbDir, "gpanel.db")
+	dbDir := DataDir
+	dbPath := DBPath()
 
 	// 确保数据目录存在
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
@@ -47,12 +55,20 @@ func InitDB() error {
 	return nil
 }
 
+// newLogger 按 logging.level 设置 SQL 日志：debug 记录所有语句，info 和 warn 记录慢查询和错误，error 只记录错误
 func newLogger() logger.Interface {
+	level := logger.Warn
+	switch LogLevel {
+	case "debug":
+		level = logger.Info
+	case "error":
+		level = logger.Error
+	}
 	return logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
+		log.New(LogWriter, "\r\n", log.LstdFlags),
 		logger.Config{
 			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Silent,
+			LogLevel:                  level,
 			IgnoreRecordNotFoundError: true,
 			Colorful:                  false,
 		},
@@ -0,0 +1,202 @@
+package global
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTKey 单个 JWT 签名密钥
+type JWTKey struct {
+	Kid       string     `json:"kid"`
+	Secret    string     `json:"secret"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RetiredAt *time.Time `json:"retiredAt,omitempty"`
+}
+
+// JWTKeyStore 管理 JWT 签名密钥，支持通过 kid 轮换
+type JWTKeyStore struct {
+	mu      sync.RWMutex
+	path    string
+	current string
+	keys    map[string]*JWTKey
+}
+
+type jwtKeyFile struct {
+	Current string    `json:"current"`
+	Keys    []*JWTKey `json:"keys"`
+}
+
+var JWTKeys *JWTKeyStore
+
+// InitJWTKeys 加载数据目录中的签名密钥，首次启动时自动生成
+func InitJWTKeys() error {
+	store := &JWTKeyStore{
+		path: filepath.Join(DataDir, "jwt_keys.json"),
+		keys: make(map[string]*JWTKey),
+	}
+
+	if err := store.load(); err != nil {
+		return err
+	}
+
+	if store.current == "" {
+		if _, err := store.Rotate(); err != nil {
+			return err
+		}
+		log.Printf("Generated new JWT signing key at: %s", store.path)
+	}
+
+	JWTKeys = store
+	return nil
+}
+
+func (s *JWTKeyStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var file jwtKeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid jwt key file %s: %w", s.path, err)
+	}
+
+	for _, key := range file.Keys {
+		s.keys[key.Kid] = key
+	}
+	if _, ok := s.keys[file.Current]; ok {
+		s.current = file.Current
+	}
+	return nil
+}
+
+// save 原子写入密钥文件，仅所有者可读写
+func (s *JWTKeyStore) save() error {
+	file := jwtKeyFile{Current: s.current}
+	for _, key := range s.keys {
+		file.Keys = append(file.Keys, key)
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// Rotate 生成新的签名密钥并设为当前密钥，旧密钥保留到已签发的 token 全部过期
+func (s *JWTKeyStore) Rotate() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret := make([]byte, 64)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	kidBytes := make([]byte, 8)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if previous, ok := s.keys[s.current]; ok {
+		previous.RetiredAt = &now
+	}
+
+	key := &JWTKey{
+		Kid:       hex.EncodeToString(kidBytes),
+		Secret:    base64.StdEncoding.EncodeToString(secret),
+		CreatedAt: now,
+	}
+	s.keys[key.Kid] = key
+	s.current = key.Kid
+	s.pruneLocked(now)
+
+	if err := s.save(); err != nil {
+		return "", err
+	}
+	return key.Kid, nil
+}
+
+// pruneLocked 删除退役时间超过最长会话时间的密钥
+func (s *JWTKeyStore) pruneLocked(now time.Time) {
+	grace := 86400 * time.Second
+	if ConfigCacheInstance != nil {
+		grace = time.Duration(ConfigCacheInstance.GetSessionTimeout()) * time.Second
+	}
+
+	for kid, key := range s.keys {
+		if key.RetiredAt != nil && now.Sub(*key.RetiredAt) > grace {
+			delete(s.keys, kid)
+		}
+	}
+}
+
+// CurrentKid 返回当前签名密钥的 kid
+func (s *JWTKeyStore) CurrentKid() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Sign 使用当前密钥签名，并在 header 中写入 kid
+func (s *JWTKeyStore) Sign(claims jwt.Claims) (string, error) {
+	s.mu.RLock()
+	key, ok := s.keys[s.current]
+	s.mu.RUnlock()
+	if !ok {
+		return "", errors.New("no jwt signing key available")
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(key.Secret)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(secret)
+}
+
+// Parse 根据 kid 选择密钥校验 token
+func (s *JWTKeyStore) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, s.keyFunc, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+}
+
+func (s *JWTKeyStore) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, errors.New("missing kid header")
+	}
+
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+
+	return base64.StdEncoding.DecodeString(key.Secret)
+}
@@ -0,0 +1,125 @@
+package global
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// initTestJWTKeys 在临时数据目录中初始化密钥库
+func initTestJWTKeys(t *testing.T) {
+	t.Helper()
+
+	DataDir = t.TempDir()
+	ConfigCacheInstance = nil
+	if err := InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	initTestJWTKeys(t)
+	claims := jwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	oldKid := JWTKeys.CurrentKid()
+	oldToken, err := JWTKeys.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKid, err := JWTKeys.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKid == oldKid {
+		t.Fatal("rotation kept the same kid")
+	}
+	newToken, err := JWTKeys.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 用旧密钥的内容伪造一个 kid 不存在的 token
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "unknown"
+	secret, _ := base64.StdEncoding.DecodeString(JWTKeys.keys[oldKid].Secret)
+	unknownKid, _ := forged.SignedString(secret)
+
+	noKid, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+
+	otherAlg := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	otherAlg.Header["kid"] = newKid
+	newSecret, _ := base64.StdEncoding.DecodeString(JWTKeys.keys[newKid].Secret)
+	wrongAlg, _ := otherAlg.SignedString(newSecret)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantKid string
+		wantErr bool
+	}{
+		{"token signed before rotation", oldToken, oldKid, false},
+		{"token signed after rotation", newToken, newKid, false},
+		{"unknown kid", unknownKid, "", true},
+		{"missing kid", noKid, "", true},
+		{"unexpected algorithm", wrongAlg, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := JWTKeys.Parse(tt.token, jwt.MapClaims{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("token should be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if kid := token.Header["kid"]; kid != tt.wantKid {
+				t.Fatalf("kid = %v, want %s", kid, tt.wantKid)
+			}
+		})
+	}
+
+	// 重新加载后当前密钥和退役密钥都应保留
+	if err := InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if JWTKeys.CurrentKid() != newKid {
+		t.Fatalf("current kid after reload = %s, want %s", JWTKeys.CurrentKid(), newKid)
+	}
+	if _, err := JWTKeys.Parse(oldToken, jwt.MapClaims{}); err != nil {
+		t.Fatalf("retired key lost after reload: %v", err)
+	}
+}
+
+func TestJWTKeyPruneAfterGrace(t *testing.T) {
+	initTestJWTKeys(t)
+
+	oldKid := JWTKeys.CurrentKid()
+	token, err := JWTKeys.Sign(jwt.MapClaims{"sub": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JWTKeys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 退役时间超过最长会话时间后，下次轮换时删除
+	retired := time.Now().Add(-25 * time.Hour)
+	JWTKeys.keys[oldKid].RetiredAt = &retired
+	if _, err := JWTKeys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := JWTKeys.keys[oldKid]; ok {
+		t.Fatal("expired retired key was not pruned")
+	}
+	if _, err := JWTKeys.Parse(token, jwt.MapClaims{}); err == nil {
+		t.Fatal("token signed with a pruned key should be rejected")
+	}
+	if len(JWTKeys.keys) != 2 {
+		t.Fatalf("got %d keys, want current and the recently retired one", len(JWTKeys.keys))
+	}
+}
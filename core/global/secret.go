@@ -0,0 +1,123 @@
+package global
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// secretPrefix 加密后的设置值前缀，便于识别旧版本遗留的明文值
+const secretPrefix = "enc:v1:"
+
+// masterKeyEnv 可通过环境变量指定主密钥文件位置，如放在数据目录之外的挂载卷
+const masterKeyEnv = "GPANEL_MASTER_KEY_FILE"
+
+var ErrMasterKeyMissing = errors.New("master key is not initialized")
+
+var masterAEAD cipher.AEAD
+
+// InitMasterKey 加载用于加密敏感设置的主密钥，首次启动时自动生成。需在读取设置之前调用
+func InitMasterKey() error {
+	return initMasterKey(true)
+}
+
+// OpenMasterKey 加载已有的主密钥，文件不存在时返回错误，用于不应生成新密钥的命令行工具
+func OpenMasterKey() error {
+	return initMasterKey(false)
+}
+
+func initMasterKey(create bool) error {
+	path := os.Getenv(masterKeyEnv)
+	if path == "" {
+		path = filepath.Join(DataDir, "master.key")
+	}
+
+	key, err := loadMasterKey(path, create)
+	if err != nil {
+		return err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	masterAEAD, err = cipher.NewGCM(block)
+	return err
+}
+
+func loadMasterKey(path string, create bool) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid master key file %s", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) || !create {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	log.Printf("Generated new master key at: %s, keep a backup of it together with the database", path)
+	return key, nil
+}
+
+// IsEncryptedSecret 判断设置值是否已加密
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+// EncryptSecret 使用主密钥以 AES-GCM 加密，空值不加密
+func EncryptSecret(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	if masterAEAD == nil {
+		return "", ErrMasterKeyMissing
+	}
+
+	nonce := make([]byte, masterAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := masterAEAD.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret 解密设置值，未加密的值原样返回
+func DecryptSecret(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+	if masterAEAD == nil {
+		return "", ErrMasterKeyMissing
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil || len(sealed) < masterAEAD.NonceSize() {
+		return "", errors.New("malformed encrypted secret")
+	}
+	nonce, ciphertext := sealed[:masterAEAD.NonceSize()], sealed[masterAEAD.NonceSize():]
+	plaintext, err := masterAEAD.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
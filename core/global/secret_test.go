@@ -0,0 +1,34 @@
+package global
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMasterKeyRequiresExistingFile(t *testing.T) {
+	DataDir = t.TempDir()
+	t.Setenv(masterKeyEnv, "")
+
+	if err := OpenMasterKey(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("err = %v, want a missing file error", err)
+	}
+	if _, err := os.Stat(filepath.Join(DataDir, "master.key")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("OpenMasterKey created a master key")
+	}
+
+	if err := InitMasterKey(); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := EncryptSecret("value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := OpenMasterKey(); err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := DecryptSecret(sealed); err != nil || plaintext != "value" {
+		t.Fatalf("DecryptSecret = %q, %v", plaintext, err)
+	}
+}
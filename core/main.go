@@ -7,7 +7,9 @@ import (
 	"gpanel/routes"
 	"gpanel/service"
 	"log"
+	"net"
 	"runtime"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,9 +21,22 @@ var (
 )
 
 func main() {
+	// 读取配置文件和环境变量，数据目录和日志配置在打开数据库之前确定
+	if err := global.LoadBootstrapConfig(); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	global.InitLogging()
+
 	// 显示版本信息
 	log.Printf("GPanel v%s (commit: %s, built: %s)", Version, GitCommit, BuildTime)
 	log.Printf("Go version: %s, OS/Arch: %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if global.ConfigFileUsed != "" {
+		log.Printf("Config file loaded: %s", global.ConfigFileUsed)
+	}
+	if err := service.ValidateSettingUpdates(global.ConfigOverrides()); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	gin.DefaultWriter = global.LogWriter
 
 	// 初始化数据库
 	if err := global.InitDB(); err != nil {
@@ -29,9 +44,23 @@ func main() {
 	}
 	defer global.CloseDB()
 
+	// 加载主密钥，用于加密敏感设置
+	if err := global.InitMasterKey(); err != nil {
+		log.Fatalf("Failed to initialize master key: %v", err)
+	}
+
 	// 自动迁移数据库表
 	if err := global.DB.AutoMigrate(
 		&models.Setting{},
+		&models.TwoFactor{},
+		&models.User{},
+		&models.APIToken{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.LoginThrottle{},
+		&models.AuditLog{},
+		&models.SettingRevision{},
+		&models.LoginEvent{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -42,11 +71,26 @@ func main() {
 		log.Printf("Warning: Failed to initialize default settings: %v", err)
 	}
 
+	// 加密旧版本明文保存的 TOTP 密钥
+	if err := service.NewTwoFactorService().EncryptSecrets(); err != nil {
+		log.Fatalf("Failed to encrypt two-factor secrets: %v", err)
+	}
+
+	// 初始化管理员账户（迁移旧版本的面板用户名和密码）
+	if err := service.NewUserService().InitializeDefaultAdmin(); err != nil {
+		log.Fatalf("Failed to initialize admin user: %v", err)
+	}
+
 	// 初始化配置缓存
 	if err := global.InitConfigCache(); err != nil {
 		log.Fatalf("Failed to initialize config cache: %v", err)
 	}
 
+	// 加载 JWT 签名密钥（首次启动时生成）
+	if err := global.InitJWTKeys(); err != nil {
+		log.Fatalf("Failed to initialize jwt signing keys: %v", err)
+	}
+
 	// 初始化配置热重载器（禁用自动重载，仅支持手动触发）
 	global.InitConfigReloader(0)
 
@@ -54,7 +98,26 @@ func main() {
 	serverMode := global.ConfigCacheInstance.GetServerMode()
 	gin.SetMode(serverMode)
 
-	r := gin.Default()
+	// 请求日志按 logging.level 开启
+	r := gin.New()
+	if global.RequestLogEnabled() {
+		r.Use(gin.Logger())
+	}
+	r.Use(gin.Recovery())
+
+	// 仅信任配置的反向代理传递的客户端 IP
+	trustedProxies := strings.FieldsFunc(global.ConfigCacheInstance.GetTrustedProxies(), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Printf("Warning: Invalid trusted proxies: %v", err)
+	}
+
+	// 添加 IP 访问控制中间件
+	r.Use(middleware.IPFilter())
+
+	// 添加域名绑定中间件
+	r.Use(middleware.DomainBinding())
 
 	// 添加安全入口中间件
 	r.Use(middleware.SecurityEntrance())
@@ -68,8 +131,9 @@ func main() {
 	// 最后注册前端路由（通配符路由）
 	SetupFrontend(r)
 
-	// 从配置缓存获取端口配置
+	// 从配置缓存获取监听地址和端口，配置文件或环境变量中的值优先
 	serverPort := global.ConfigCacheInstance.GetServerPort()
+	listenAddress := global.ConfigCacheInstance.GetListenAddress()
 
 	// 从配置缓存获取安全入口配置
 	securityEntrance := global.ConfigCacheInstance.GetSecurityEntrance()
@@ -81,7 +145,11 @@ func main() {
 		log.Printf("========================================")
 	}
 
-	addr := ":" + serverPort
+	// 0.0.0.0 视为监听所有地址，同时接受 IPv4 和 IPv6 连接
+	if listenAddress == "0.0.0.0" {
+		listenAddress = ""
+	}
+	addr := net.JoinHostPort(listenAddress, serverPort)
 	log.Printf("Starting GPanel server on %s", addr)
 	log.Printf("Security entrance: %s", securityEntrance)
 	log.Printf("Language: %s, Timezone: %s", global.ConfigCacheInstance.GetLanguage(), global.ConfigCacheInstance.GetTimezone())
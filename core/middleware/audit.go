@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/models"
+	"gpanel/service"
+)
+
+// auditMethods 需要记录审计日志的请求方法
+var auditMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// Audit 记录修改类请求的审计日志。用户信息由 Auth() 写入上下文，
+// 未登录的请求（如登录接口）使用控制器写入的 auditUsername，设置差异由控制器写入 auditChanges。
+// 未登录且失败的请求不记录，避免匿名请求撑大审计表，登录失败由 login_events 记录
+func Audit() gin.HandlerFunc {
+	auditService := service.NewAuditService()
+
+	return func(c *gin.Context) {
+		c.Next()
+
+		// GET 类请求只有控制器标记 auditForce 时才记录（如单点登录回调）
+		if !auditMethods[c.Request.Method] && !c.GetBool("auditForce") {
+			return
+		}
+
+		if c.GetUint("userID") == 0 && c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+
+		username := c.GetString("username")
+		if username == "" {
+			username = c.GetString("auditUsername")
+		}
+
+		entry := &models.AuditLog{
+			UserID:   c.GetUint("userID"),
+			Username: username,
+			IP:       c.ClientIP(),
+			Method:   c.Request.Method,
+			Route:    c.FullPath(),
+			Path:     c.Request.URL.Path,
+			Status:   c.Writer.Status(),
+		}
+		if changes, ok := c.Get("auditChanges"); ok {
+			entry.Changes, _ = changes.(map[string]models.AuditChange)
+		}
+
+		if err := auditService.Record(entry); err != nil {
+			log.Printf("Failed to record audit log: %v", err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/repo"
+	"gpanel/service"
+)
+
+// setupAuditTestDB 在临时目录中创建审计日志和设置表
+func setupAuditTestDB(t *testing.T) {
+	t.Helper()
+
+	global.DataDir = t.TempDir()
+	if err := global.InitDB(); err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	t.Cleanup(global.CloseDB)
+	if err := global.DB.AutoMigrate(&models.Setting{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+}
+
+// newAuditTestRouter X-Test-User 模拟已登录用户，status 参数指定响应状态码
+func newAuditTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if username := c.GetHeader("X-Test-User"); username != "" {
+			c.Set("userID", uint(1))
+			c.Set("username", username)
+		}
+	}, Audit())
+
+	handler := func(c *gin.Context) {
+		status, _ := strconv.Atoi(c.DefaultQuery("status", "200"))
+		c.Status(status)
+	}
+	router.GET("/api/v1/settings", handler)
+	router.POST("/api/v1/auth/login", func(c *gin.Context) {
+		c.Set("auditUsername", "bob")
+		handler(c)
+	})
+	router.PUT("/api/v1/settings", func(c *gin.Context) {
+		c.Set("auditChanges", service.SettingChanges(map[string]string{"PanelPassword": "New-Secret-123"}))
+		handler(c)
+	})
+	return router
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		user         string
+		wantRecorded bool
+		wantUsername string
+	}{
+		{"read request", http.MethodGet, "/api/v1/settings", "admin", false, ""},
+		{"authenticated change", http.MethodPut, "/api/v1/settings", "admin", true, "admin"},
+		{"authenticated failure", http.MethodPut, "/api/v1/settings?status=400", "admin", true, "admin"},
+		{"login", http.MethodPost, "/api/v1/auth/login", "", true, "bob"},
+		{"failed login", http.MethodPost, "/api/v1/auth/login?status=401", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupAuditTestDB(t)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.user != "" {
+				req.Header.Set("X-Test-User", tt.user)
+			}
+			newAuditTestRouter().ServeHTTP(httptest.NewRecorder(), req)
+
+			logs, total, err := service.NewAuditService().List(repo.AuditLogFilter{}, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recorded := total > 0; recorded != tt.wantRecorded {
+				t.Fatalf("recorded = %v, want %v", recorded, tt.wantRecorded)
+			}
+			if !tt.wantRecorded {
+				return
+			}
+			entry := logs[0]
+			if entry.Username != tt.wantUsername || entry.Method != tt.method || entry.Route != req.URL.Path {
+				t.Fatalf("entry = %+v", entry)
+			}
+			if change, ok := entry.Changes["PanelPassword"]; tt.method == http.MethodPut && (!ok || change.After != "******" || change.Before != "******") {
+				t.Fatalf("password change not redacted: %+v", entry.Changes)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -10,14 +9,52 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"gpanel/global"
+	"gpanel/service"
 )
 
-var jwtSecret = []byte("gpanel-secret-key-change-in-production")
+// twoFactorSetupRoutes 强制双因素认证时，未绑定用户的受限 token 仅能访问的路由
+var twoFactorSetupRoutes = map[string]bool{
+	"/api/v1/auth/2fa/status": true,
+	"/api/v1/auth/2fa/setup":  true,
+	"/api/v1/auth/2fa/enable": true,
+}
 
+// passwordChangeRoutes 必须修改密码时仅能访问的路由
+var passwordChangeRoutes = map[string]bool{
+	"/api/v1/auth/me":       true,
+	"/api/v1/auth/password": true,
+	"/api/v1/auth/logout":   true,
+}
+
+// Auth 校验 JWT 或个人访问令牌，并将用户信息存入上下文。
+// cookie 模式下没有 Authorization 请求头时从 cookie 读取访问 token
 func Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		tokenString := ""
+		if authHeader != "" {
+			// 检查 Bearer token 格式
+			parts := strings.SplitN(authHeader, " ", 2)
+			if !(len(parts) == 2 && parts[0] == "Bearer") {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Invalid authorization format",
+				})
+				c.Abort()
+				return
+			}
+			tokenString = parts[1]
+		} else if cookieToken, ok := cookieAccessToken(c); ok {
+			// cookie 会随跨站请求自动发送，修改类请求需校验 CSRF token
+			if !safeMethods[c.Request.Method] && !hasValidCSRFToken(c) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Invalid CSRF token",
+					"code":  "csrf_invalid",
+				})
+				c.Abort()
+				return
+			}
+			tokenString = cookieToken
+		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Authorization header required",
 			})
@@ -25,32 +62,32 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
-		// 检查 Bearer token 格式
-		parts := strings.SplitN(authHeader, " ", 2)
-		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid authorization format",
-			})
-			c.Abort()
+		// 个人访问令牌，只能通过请求头传递
+		if service.IsAPIToken(tokenString) && authHeader != "" {
+			apiToken, user, err := service.NewAPITokenService().Authenticate(tokenString)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Invalid token",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("userID", user.ID)
+			c.Set("username", user.Username)
+			c.Set("role", user.Role)
+			c.Set("apiTokenID", apiToken.ID)
+			c.Set("scopes", apiToken.Scopes)
+			c.Next()
 			return
 		}
 
-		tokenString := parts[1]
-
-		// 验证 token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// 验证签名算法
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtSecret, nil
-		})
+		// 验证 token（根据 kid 选择签名密钥）
+		token, err := global.JWTKeys.Parse(tokenString, jwt.MapClaims{})
 
 		if err != nil {
 			if errors.Is(err, jwt.ErrTokenExpired) {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": "Token expired, please login again",
-				})
+				respondTokenExpired(c)
 			} else {
 				c.JSON(http.StatusUnauthorized, gin.H{
 					"error": "Invalid token",
@@ -70,16 +107,73 @@ func Auth() gin.HandlerFunc {
 
 		// 将用户信息存入上下文
 		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("username", claims["username"])
-			c.Set("role", claims["role"])
+			// 只接受访问 token，双因素认证前的临时 token 不能访问接口
+			if claims["typ"] != "access" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Invalid token",
+				})
+				c.Abort()
+				return
+			}
+
+			// 从数据库加载用户，角色变更和禁用立即生效
+			uid, _ := claims["uid"].(float64)
+			user, err := service.NewUserService().GetByID(uint(uid))
+			if err != nil || user.Disabled {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "User is disabled or no longer exists",
+				})
+				c.Abort()
+				return
+			}
+
+			// 校验服务端会话，已退出或被终止的会话立即失效
+			jti, _ := claims["jti"].(string)
+			session, err := service.NewSessionService().Validate(jti)
+			if err != nil || session.UserID != user.ID {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Session has been terminated, please login again",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("userID", user.ID)
+			c.Set("username", user.Username)
+			c.Set("role", user.Role)
+			c.Set("sessionID", session.ID)
+			c.Set("jti", jti)
+
+			// 必须修改密码或强制绑定双因素认证时，只允许访问对应的接口
+			twoFactorSetup, _ := claims["tfa_setup"].(bool)
+			mustChangePassword := service.MustChangePassword(user)
+			if twoFactorSetup || mustChangePassword {
+				path := c.FullPath()
+				allowed := (twoFactorSetup && twoFactorSetupRoutes[path]) ||
+					(mustChangePassword && passwordChangeRoutes[path])
+				if !allowed {
+					if mustChangePassword {
+						c.JSON(http.StatusForbidden, gin.H{
+							"error": "Password change required",
+							"code":  "password_change_required",
+						})
+					} else {
+						c.JSON(http.StatusForbidden, gin.H{
+							"error": "Two-factor authentication setup required",
+							"code":  "2fa_setup_required",
+						})
+					}
+					c.Abort()
+					return
+				}
+				c.Set("twoFactorSetup", twoFactorSetup)
+			}
 
 			// 检查过期时间
 			if exp, ok := claims["exp"].(float64); ok {
 				expTime := time.Unix(int64(exp), 0)
 				if time.Now().After(expTime) {
-					c.JSON(http.StatusUnauthorized, gin.H{
-						"error": "Token expired, please login again",
-					})
+					respondTokenExpired(c)
 					c.Abort()
 					return
 				}
@@ -102,4 +196,52 @@ func Auth() gin.HandlerFunc {
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// safeMethods 不修改数据的请求方法，无需校验 CSRF token
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// cookieAccessToken cookie 模式下从 HttpOnly cookie 读取访问 token
+func cookieAccessToken(c *gin.Context) (string, bool) {
+	if !service.CookieAuthEnabled() {
+		return "", false
+	}
+	token, err := c.Cookie(service.AccessCookieName)
+	if err != nil || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// hasValidCSRFToken 双重提交校验：请求头中的 CSRF token 必须与 cookie 一致
+func hasValidCSRFToken(c *gin.Context) bool {
+	cookieToken, _ := c.Cookie(service.CSRFCookieName)
+	return service.ValidCSRFToken(cookieToken, c.GetHeader(service.CSRFHeaderName))
+}
+
+// respondTokenExpired 访问 token 过期，客户端应使用刷新令牌换发新 token 后重试
+func respondTokenExpired(c *gin.Context) {
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error": "Token expired",
+		"code":  "token_expired",
+	})
+}
+
+// RequireSession 仅允许登录会话访问，拒绝个人访问令牌，需在 Auth() 之后使用
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiTokenID"); ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "API tokens cannot access this endpoint",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
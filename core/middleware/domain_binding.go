@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+	"gpanel/service"
+	"gpanel/utils"
+)
+
+var boundDomains atomic.Pointer[[]string]
+
+// ReloadDomainBinding 从配置缓存重新加载绑定的域名
+func ReloadDomainBinding() {
+	if global.ConfigCacheInstance == nil {
+		return
+	}
+
+	domains := service.BoundDomains(
+		global.ConfigCacheInstance.GetBindDomain(),
+		global.ConfigCacheInstance.GetServerAddress(),
+	)
+	if global.ConfigCacheInstance.GetBindDomain() && len(domains) == 0 {
+		log.Printf("Domain binding is enabled but ServerAddress has no domain, binding is ignored")
+	}
+	boundDomains.Store(&domains)
+}
+
+// DomainBinding 开启域名绑定后，Host 不匹配的请求按配置的响应方式拒绝，需在 SecurityEntrance() 之前注册
+func DomainBinding() gin.HandlerFunc {
+	ReloadDomainBinding()
+	if global.ConfigReloaderInstance != nil {
+		global.ConfigReloaderInstance.OnReload(ReloadDomainBinding)
+	}
+
+	return func(c *gin.Context) {
+		domains := boundDomains.Load()
+		if domains != nil && len(*domains) > 0 && !utils.MatchDomain(*domains, c.Request.Host) {
+			abortUnauthenticated(c)
+			return
+		}
+
+		c.Next()
+	}
+}
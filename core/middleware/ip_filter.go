@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+	"gpanel/service"
+)
+
+var ipAccessLists atomic.Pointer[service.IPAccessLists]
+
+// ReloadIPFilter 从配置缓存重新加载 IP 白名单和黑名单，解析失败时保留原列表
+func ReloadIPFilter() {
+	if global.ConfigCacheInstance == nil {
+		return
+	}
+
+	lists, err := service.ParseIPAccessLists(
+		global.ConfigCacheInstance.GetIPAllowList(),
+		global.ConfigCacheInstance.GetIPDenyList(),
+	)
+	if err != nil {
+		log.Printf("Failed to load IP access lists: %v", err)
+		return
+	}
+	ipAccessLists.Store(lists)
+}
+
+// IPFilter 按 IP 白名单和黑名单限制面板访问，需在 SecurityEntrance() 之前注册
+func IPFilter() gin.HandlerFunc {
+	ReloadIPFilter()
+	if global.ConfigReloaderInstance != nil {
+		global.ConfigReloaderInstance.OnReload(ReloadIPFilter)
+	}
+
+	return func(c *gin.Context) {
+		lists := ipAccessLists.Load()
+		if lists != nil && !lists.Allows(net.ParseIP(c.ClientIP())) {
+			abortUnauthenticated(c)
+			return
+		}
+
+		c.Next()
+	}
+}
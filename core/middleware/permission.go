@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/models"
+)
+
+// RequirePermission 校验当前用户角色是否拥有指定权限，需在 Auth() 之后使用
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Permission denied",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasPermission 当前用户角色是否拥有指定权限。使用个人访问令牌时，还要求令牌的 scope 包含该权限
+func HasPermission(c *gin.Context, permission string) bool {
+	allowed := models.RoleHasPermission(c.GetString("role"), permission)
+	if scopes, ok := c.Get("scopes"); ok && allowed {
+		allowed = slices.Contains(scopes.([]string), permission)
+	}
+	return allowed
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+)
+
+// 未通过安全入口、域名绑定或 IP 校验时的响应方式
+const (
+	unauthModePage     = "page"
+	unauthModeNotFound = "404"
+	unauthModeNginx404 = "nginx404"
+	unauthModeCustom   = "custom"
+	unauthModeDrop     = "drop"
+)
+
+// unauthPageText 提示页面文案，按 Language 设置选择
+type unauthPageText struct {
+	Lang        string
+	Title       string
+	Description string
+	Instruction string
+}
+
+var unauthPageTexts = map[string]unauthPageText{
+	"zh": {
+		Lang:        "zh-CN",
+		Title:       "暂时无法访问",
+		Description: "当前环境已经开启了安全入口登录",
+		Instruction: "可在 SSH 终端输入以下命令来查看面板入口：",
+	},
+	"en": {
+		Lang:        "en",
+		Title:       "Access Unavailable",
+		Description: "The security entrance is enabled on this server",
+		Instruction: "Run the following command in an SSH terminal to view the panel entrance:",
+	},
+}
+
+// nginx404HTML 与 nginx 默认 404 页面一致
+const nginx404HTML = `<html>
+<head><title>404 Not Found</title></head>
+<body>
+<center><h1>404 Not Found</h1></center>
+<hr><center>nginx</center>
+</body>
+</html>
+`
+
+// abortUnauthenticated 按 UnauthResponseMode 响应未通过校验的请求。
+// API 请求不返回页面，page 和 custom 模式下返回空的 404
+func abortUnauthenticated(c *gin.Context) {
+	mode := unauthModePage
+	if global.ConfigCacheInstance != nil {
+		mode = global.ConfigCacheInstance.GetUnauthResponseMode()
+	}
+
+	isAPI := strings.HasPrefix(c.Request.URL.Path, "/api")
+	switch {
+	case mode == unauthModeDrop:
+		dropConnection(c)
+	case mode == unauthModeNginx404:
+		c.Header("Server", "nginx")
+		c.Data(http.StatusNotFound, "text/html", []byte(nginx404HTML))
+		c.Abort()
+	case mode == unauthModeNotFound || isAPI:
+		c.AbortWithStatus(http.StatusNotFound)
+	case mode == unauthModeCustom && global.ConfigCacheInstance.GetUnauthCustomPage() != "":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(global.ConfigCacheInstance.GetUnauthCustomPage()))
+		c.Abort()
+	default:
+		writeUnauthPage(c)
+	}
+}
+
+// writeUnauthPage 返回提示页面，中文以外的语言使用英文
+func writeUnauthPage(c *gin.Context) {
+	text := unauthPageTexts["en"]
+	if global.ConfigCacheInstance == nil || strings.HasPrefix(global.ConfigCacheInstance.GetLanguage(), "zh") {
+		text = unauthPageTexts["zh"]
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	_ = unauthPageTemplate.Execute(c.Writer, text)
+	c.Abort()
+}
+
+// dropConnection 不返回任何响应直接关闭连接，无法接管连接时（如 HTTP/2）退化为空的 404
+func dropConnection(c *gin.Context) {
+	c.Abort()
+	// gin 在底层连接不支持接管时会直接 panic，需要先检查
+	if w, ok := c.Writer.(interface{ Unwrap() http.ResponseWriter }); ok {
+		if _, ok := w.Unwrap().(http.Hijacker); !ok {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+	}
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	_ = conn.Close()
+}
+
+var unauthPageTemplate = template.Must(template.New("unauth").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}} - GPanel</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', 'PingFang SC', 'Hiragino Sans GB',
+                'Microsoft YaHei', 'Helvetica Neue', Helvetica, Arial, sans-serif;
+            background: #F5F5F5;
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            padding: 20px;
+        }
+
+        .not-found-card {
+            background: #FFFFFF;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0, 0, 0, 0.05);
+            padding: 32px 40px;
+            max-width: 500px;
+            width: 100%;
+            text-align: center;
+        }
+
+        .icon-wrapper {
+            margin-bottom: 24px;
+        }
+
+        .lock-icon {
+            width: 64px;
+            height: 64px;
+            color: #667eea;
+        }
+
+        .title {
+            font-size: 22px;
+            font-weight: 600;
+            color: #333333;
+            margin: 0 0 16px 0;
+        }
+
+        .description {
+            font-size: 15px;
+            color: #666666;
+            line-height: 1.6;
+            margin: 0 0 12px 0;
+        }
+
+        .instruction {
+            font-size: 15px;
+            color: #666666;
+            line-height: 1.6;
+            margin: 0 0 16px 0;
+        }
+
+        .code-block {
+            display: inline-block;
+            background: #EFEFEF;
+            border-radius: 4px;
+            padding: 8px 12px;
+            margin-top: 16px;
+        }
+
+        .code-block code {
+            font-family: 'Courier New', 'Consolas', 'Monaco', monospace;
+            font-size: 14px;
+            color: #333333;
+            font-weight: 500;
+        }
+
+        @media (max-width: 480px) {
+            .not-found-card {
+                padding: 24px 20px;
+            }
+
+            .title {
+                font-size: 20px;
+            }
+
+            .description,
+            .instruction {
+                font-size: 14px;
+            }
+        }
+    </style>
+</head>
+<body>
+    <div class="not-found-card">
+        <div class="icon-wrapper">
+            <svg class="lock-icon" viewBox="0 0 24 24" fill="none" xmlns="http://www.w3.org/2000/svg">
+                <path d="M12 2C9.243 2 7 4.243 7 7V10H6C4.89543 10 4 10.8954 4 12V20C4 21.1046 4.89543 22 6 22H18C19.1046 22 20 21.1046 20 20V12C20 10.8954 19.1046 10 18 10H17V7C17 4.243 14.757 2 12 2ZM9 7C9 5.34315 10.3431 4 12 4C13.6569 4 15 5.34315 15 7V10H9V7ZM6 12H18V20H6V12Z" fill="#667eea"/>
+            </svg>
+        </div>
+        <h1 class="title">{{.Title}}</h1>
+        <p class="description">{{.Description}}</p>
+        <p class="instruction">{{.Instruction}}</p>
+        <div class="code-block">
+            <code>gpctl user-info</code>
+        </div>
+    </div>
+</body>
+</html>`))
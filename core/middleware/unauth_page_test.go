@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gpanel/global"
+)
+
+// newUnauthTestRouter 拒绝所有来自 httptest 默认地址 192.0.2.1 的请求
+func newUnauthTestRouter(t *testing.T, settings map[string]string) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	global.ConfigCacheInstance = &global.ConfigCache{}
+	global.ConfigCacheInstance.SetBatch(settings, nil, nil)
+	global.ConfigCacheInstance.Set("IPDenyList", "192.0.2.1, 127.0.0.1")
+	t.Cleanup(func() {
+		global.ConfigCacheInstance = nil
+		ipAccessLists.Store(nil)
+	})
+
+	router := gin.New()
+	router.Use(IPFilter())
+	router.NoRoute(func(c *gin.Context) { c.String(http.StatusOK, "panel") })
+	return router
+}
+
+func TestUnauthResponseModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   map[string]string
+		path       string
+		wantStatus int
+		wantBody   string
+		wantServer string
+	}{
+		{"default page", nil, "/", http.StatusOK, "暂时无法访问", ""},
+		{"english page", map[string]string{"Language": "en"}, "/", http.StatusOK, "Access Unavailable", ""},
+		{"page mode API", map[string]string{"UnauthResponseMode": "page"}, "/api/v1/settings", http.StatusNotFound, "", ""},
+		{"404", map[string]string{"UnauthResponseMode": "404"}, "/", http.StatusNotFound, "", ""},
+		{"nginx 404", map[string]string{"UnauthResponseMode": "nginx404"}, "/api/v1/settings", http.StatusNotFound, "<center>nginx</center>", "nginx"},
+		{"custom page", map[string]string{"UnauthResponseMode": "custom", "UnauthCustomPage": "<h1>Maintenance</h1>"}, "/", http.StatusOK, "<h1>Maintenance</h1>", ""},
+		{"custom page API", map[string]string{"UnauthResponseMode": "custom", "UnauthCustomPage": "<h1>Maintenance</h1>"}, "/api/v1/settings", http.StatusNotFound, "", ""},
+		{"custom without page", map[string]string{"UnauthResponseMode": "custom"}, "/", http.StatusOK, "暂时无法访问", ""},
+		{"drop falls back without hijack", map[string]string{"UnauthResponseMode": "drop"}, "/", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newUnauthTestRouter(t, tt.settings)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			body := w.Body.String()
+			if tt.wantBody == "" && body != "" || !strings.Contains(body, tt.wantBody) {
+				t.Fatalf("body = %q, want %q", body, tt.wantBody)
+			}
+			if server := w.Header().Get("Server"); server != tt.wantServer {
+				t.Fatalf("Server header = %q, want %q", server, tt.wantServer)
+			}
+		})
+	}
+}
+
+func TestUnauthDropClosesConnection(t *testing.T) {
+	router := newUnauthTestRouter(t, map[string]string{"UnauthResponseMode": "drop"})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	if err == nil {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		t.Fatalf("got %d %q, want the connection to be closed", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// APIToken 用于自动化脚本的个人访问令牌，仅保存令牌的哈希
+type APIToken struct {
+	BaseModel
+	UserID     uint       `json:"userId" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"type:varchar(256);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(32)"`
+	TokenHash  string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Scopes     []string   `json:"scopes" gorm:"type:text;serializer:json"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// AuditChange 设置项修改前后的值
+type AuditChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// AuditLog 修改类操作和登录的审计记录
+type AuditLog struct {
+	BaseModel
+	UserID   uint                   `json:"userId" gorm:"index"`
+	Username string                 `json:"username" gorm:"type:varchar(256);index"`
+	IP       string                 `json:"ip" gorm:"type:varchar(64)"`
+	Method   string                 `json:"method" gorm:"type:varchar(16)"`
+	Route    string                 `json:"route" gorm:"type:varchar(256);index"`
+	Path     string                 `json:"path" gorm:"type:varchar(512)"`
+	Status   int                    `json:"status"`
+	Changes  map[string]AuditChange `json:"changes,omitempty" gorm:"type:text;serializer:json"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// 登录方式
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodTwoFactor = "2fa"
+	LoginMethodOIDC      = "oidc"
+)
+
+// LoginEvent 登录记录，成功和失败都会记录
+type LoginEvent struct {
+	BaseModel
+	UserID   uint   `json:"userId" gorm:"index"`
+	Username string `json:"username" gorm:"type:varchar(256);index"`
+	IP       string `json:"ip" gorm:"type:varchar(64)"`
+	// Network 用于判断登录位置的网段，IPv4 取 /24，IPv6 取 /48
+	Network   string `json:"network" gorm:"type:varchar(64)"`
+	UserAgent string `json:"userAgent" gorm:"type:varchar(512)"`
+	Method    string `json:"method" gorm:"type:varchar(16)"`
+	Success   bool   `json:"success" gorm:"index"`
+	Reason    string `json:"reason,omitempty" gorm:"type:varchar(256)"`
+	// NewNetwork、NewUserAgent 该用户此前从未在该网段或该客户端成功登录
+	NewNetwork   bool `json:"newNetwork"`
+	NewUserAgent bool `json:"newUserAgent"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// 登录限流维度
+const (
+	ThrottleKindIP   = "ip"
+	ThrottleKindUser = "user"
+)
+
+// LoginThrottle 按 IP 或用户名统计的登录失败记录
+type LoginThrottle struct {
+	BaseModel
+	Kind          string     `json:"kind" gorm:"type:varchar(16);not null;uniqueIndex:idx_login_throttle_kind_key"`
+	Key           string     `json:"key" gorm:"type:varchar(256);not null;uniqueIndex:idx_login_throttle_kind_key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	BlockedUntil  *time.Time `json:"blockedUntil"`
+}
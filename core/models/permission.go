@@ -0,0 +1,54 @@
+package models
+
+// 权限标识，同时用作 API token 的 scope
+const (
+	PermSystemRead     = "system:read"
+	PermSettingsRead   = "settings:read"
+	PermSettingsWrite  = "settings:write"
+	PermServerRestart  = "server:restart"
+	PermUsersManage    = "users:manage"
+	PermSecurityManage = "security:manage"
+	PermAuditRead      = "audit:read"
+)
+
+// AllPermissions 全部权限
+var AllPermissions = []string{
+	PermSystemRead,
+	PermSettingsRead,
+	PermSettingsWrite,
+	PermServerRestart,
+	PermUsersManage,
+	PermSecurityManage,
+	PermAuditRead,
+}
+
+// RolePermissions 各角色拥有的权限
+var RolePermissions = map[string][]string{
+	RoleAdmin: AllPermissions,
+	RoleOperator: {
+		PermSystemRead,
+		PermSettingsRead,
+		PermSettingsWrite,
+		PermServerRestart,
+	},
+	RoleReadOnly: {
+		PermSystemRead,
+		PermSettingsRead,
+	},
+}
+
+// IsValidRole 判断角色是否存在
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission 判断角色是否拥有指定权限
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
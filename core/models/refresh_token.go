@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RefreshToken 会话的刷新令牌，每次使用后轮换。已使用的令牌再次出现视为泄露，整个会话会被吊销
+type RefreshToken struct {
+	BaseModel
+	SessionID uint       `json:"sessionId" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	UsedAt    *time.Time `json:"usedAt"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Session 服务端登录会话，通过 JWT 的 jti 关联。IdleExpiresAt 随刷新顺延，ExpiresAt 为绝对过期时间，
+// ConfigVersion 为登录时的配置版本，配置重新加载后会话无法再刷新
+type Session struct {
+	BaseModel
+	JTI           string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	UserID        uint       `json:"userId" gorm:"not null;index"`
+	Username      string     `json:"username" gorm:"type:varchar(256)"`
+	IP            string     `json:"ip" gorm:"type:varchar(64)"`
+	UserAgent     string     `json:"userAgent" gorm:"type:text"`
+	LastSeenAt    time.Time  `json:"lastSeenAt"`
+	IdleExpiresAt time.Time  `json:"idleExpiresAt"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	ConfigVersion string     `json:"-" gorm:"type:varchar(32)"`
+	RevokedAt     *time.Time `json:"revokedAt"`
+}
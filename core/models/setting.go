@@ -5,4 +5,6 @@ type Setting struct {
 	Key   string `json:"key" gorm:"type:varchar(256);not null;uniqueIndex"`
 	Value string `json:"value" gorm:"type:text"`
 	About string `json:"about" gorm:"type:text"`
+	// Secret 敏感设置，数据库中加密保存，接口只返回掩码
+	Secret bool `json:"secret" gorm:"not null;default:false"`
 }
\ No newline at end of file
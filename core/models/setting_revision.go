@@ -0,0 +1,19 @@
+package models
+
+// SettingRevision 设置项的一次修改记录，值与数据库中保存的一致，敏感设置为密文
+type SettingRevision struct {
+	BaseModel
+	// Batch 同一次提交修改的设置项共用一个批次号，可按批次整体回滚
+	Batch    string `json:"batch" gorm:"type:varchar(32);index"`
+	Key      string `json:"key" gorm:"type:varchar(256);index"`
+	OldValue string `json:"oldValue" gorm:"type:text"`
+	NewValue string `json:"newValue" gorm:"type:text"`
+	Secret   bool   `json:"secret"`
+	// Created 修改前设置项不存在，Deleted 设置项被删除
+	Created  bool   `json:"created"`
+	Deleted  bool   `json:"deleted"`
+	UserID   uint   `json:"userId" gorm:"index"`
+	Username string `json:"username" gorm:"type:varchar(256)"`
+	// RollbackOf 回滚产生的修订记录被回滚的修订 ID
+	RollbackOf uint `json:"rollbackOf,omitempty"`
+}
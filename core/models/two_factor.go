@@ -0,0 +1,11 @@
+package models
+
+// TwoFactor 用户的 TOTP 双因素认证配置，Secret 使用主密钥加密保存
+type TwoFactor struct {
+	BaseModel
+	Username      string `json:"username" gorm:"type:varchar(256);not null;uniqueIndex"`
+	Secret        string `json:"-" gorm:"type:varchar(256)"`
+	Enabled       bool   `json:"enabled"`
+	RecoveryCodes string `json:"-" gorm:"type:text"`
+	LastUsedStep  int64  `json:"-"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// 用户来源
+const (
+	UserSourceLocal = "local"
+	UserSourceLDAP  = "ldap"
+	UserSourceOIDC  = "oidc"
+)
+
+// 用户角色
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleReadOnly = "readonly"
+)
+
+// User 面板用户，MustChangePassword 为 true 时登录后只能访问修改密码接口
+type User struct {
+	BaseModel
+	Username           string     `json:"username" gorm:"type:varchar(256);not null;uniqueIndex"`
+	PasswordHash       string     `json:"-" gorm:"type:varchar(256)"`
+	Role               string     `json:"role" gorm:"type:varchar(32);not null"`
+	Disabled           bool       `json:"disabled"`
+	MustChangePassword bool       `json:"mustChangePassword"`
+	PasswordChangedAt  *time.Time `json:"passwordChangedAt"`
+	Source             string     `json:"source" gorm:"type:varchar(32);default:local"`
+	// OIDCIssuer、OIDCSubject 单点登录用户的 iss 和 sub，登录时按二者识别用户，用户名只在首次登录时使用
+	OIDCIssuer  string `json:"-" gorm:"column:oidc_issuer;type:varchar(512);index:idx_users_oidc_subject"`
+	OIDCSubject string `json:"-" gorm:"column:oidc_subject;type:varchar(256);index:idx_users_oidc_subject"`
+}
+
+// IsLocal 是否为本地账户，外部认证的账户没有本地密码
+func (u *User) IsLocal() bool {
+	return u.Source == "" || u.Source == UserSourceLocal
+}
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+type APITokenRepo struct{}
+
+type IAPITokenRepo interface {
+	List() ([]models.APIToken, error)
+	ListByUser(userID uint) ([]models.APIToken, error)
+	GetByID(id uint) (*models.APIToken, error)
+	GetByHash(hash string) (*models.APIToken, error)
+	Create(token *models.APIToken) error
+	Revoke(id uint, at time.Time) error
+	RevokeByUser(userID uint, at time.Time) error
+	UpdateLastUsed(id uint, at time.Time) error
+}
+
+func NewAPITokenRepo() IAPITokenRepo {
+	return &APITokenRepo{}
+}
+
+func (r *APITokenRepo) List() ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := global.DB.Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *APITokenRepo) ListByUser(userID uint) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := global.DB.Where("user_id = ?", userID).Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *APITokenRepo) GetByID(id uint) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := global.DB.First(&token, id).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *APITokenRepo) GetByHash(hash string) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := global.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *APITokenRepo) Create(token *models.APIToken) error {
+	return global.DB.Create(token).Error
+}
+
+func (r *APITokenRepo) Revoke(id uint, at time.Time) error {
+	return global.DB.Model(&models.APIToken{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at).Error
+}
+
+func (r *APITokenRepo) RevokeByUser(userID uint, at time.Time) error {
+	return global.DB.Model(&models.APIToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", at).Error
+}
+
+func (r *APITokenRepo) UpdateLastUsed(id uint, at time.Time) error {
+	return global.DB.Model(&models.APIToken{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+// AuditLogFilter 审计日志查询条件，零值表示不过滤
+type AuditLogFilter struct {
+	Username string
+	IP       string
+	Method   string
+	Route    string
+	Status   int
+	From     *time.Time
+	To       *time.Time
+}
+
+type AuditLogRepo struct{}
+
+type IAuditLogRepo interface {
+	Create(log *models.AuditLog) error
+	List(filter AuditLogFilter, offset, limit int) ([]models.AuditLog, int64, error)
+	DeleteBefore(before time.Time) error
+}
+
+func NewAuditLogRepo() IAuditLogRepo {
+	return &AuditLogRepo{}
+}
+
+func (r *AuditLogRepo) Create(log *models.AuditLog) error {
+	return global.DB.Create(log).Error
+}
+
+// List 按时间倒序查询，limit 小于等于 0 时不分页
+func (r *AuditLogRepo) List(filter AuditLogFilter, offset, limit int) ([]models.AuditLog, int64, error) {
+	query := applyAuditLogFilter(global.DB.Model(&models.AuditLog{}), filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("id desc").Offset(offset)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var logs []models.AuditLog
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+func (r *AuditLogRepo) DeleteBefore(before time.Time) error {
+	return global.DB.Where("created_at < ?", before).Delete(&models.AuditLog{}).Error
+}
+
+func applyAuditLogFilter(query *gorm.DB, filter AuditLogFilter) *gorm.DB {
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Method != "" {
+		query = query.Where("method = ?", filter.Method)
+	}
+	if filter.Route != "" {
+		query = query.Where("route = ?", filter.Route)
+	}
+	if filter.Status != 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+	return query
+}
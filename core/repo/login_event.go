@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+// LoginEventFilter 登录记录查询条件，零值表示不过滤
+type LoginEventFilter struct {
+	UserID   uint
+	Username string
+	IP       string
+	Success  *bool
+	From     *time.Time
+	To       *time.Time
+}
+
+type LoginEventRepo struct{}
+
+type ILoginEventRepo interface {
+	Create(event *models.LoginEvent) error
+	List(filter LoginEventFilter, offset, limit int) ([]models.LoginEvent, int64, error)
+	CountSuccess(userID uint) (int64, error)
+	HasSuccessFromNetwork(userID uint, network string) (bool, error)
+	HasSuccessWithUserAgent(userID uint, userAgent string) (bool, error)
+	DeleteBefore(before time.Time) error
+}
+
+func NewLoginEventRepo() ILoginEventRepo {
+	return &LoginEventRepo{}
+}
+
+func (r *LoginEventRepo) Create(event *models.LoginEvent) error {
+	return global.DB.Create(event).Error
+}
+
+// List 按时间倒序查询
+func (r *LoginEventRepo) List(filter LoginEventFilter, offset, limit int) ([]models.LoginEvent, int64, error) {
+	query := applyLoginEventFilter(global.DB.Model(&models.LoginEvent{}), filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []models.LoginEvent
+	if err := query.Order("id desc").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+func (r *LoginEventRepo) CountSuccess(userID uint) (int64, error) {
+	var count int64
+	err := global.DB.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND success = ?", userID, true).
+		Count(&count).Error
+	return count, err
+}
+
+// HasSuccessFromNetwork 用户是否曾从该网段成功登录
+func (r *LoginEventRepo) HasSuccessFromNetwork(userID uint, network string) (bool, error) {
+	var count int64
+	err := global.DB.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND success = ? AND network = ?", userID, true, network).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// HasSuccessWithUserAgent 用户是否曾使用该 User-Agent 成功登录
+func (r *LoginEventRepo) HasSuccessWithUserAgent(userID uint, userAgent string) (bool, error) {
+	var count int64
+	err := global.DB.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND success = ? AND user_agent = ?", userID, true, userAgent).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *LoginEventRepo) DeleteBefore(before time.Time) error {
+	return global.DB.Where("created_at < ?", before).Delete(&models.LoginEvent{}).Error
+}
+
+func applyLoginEventFilter(query *gorm.DB, filter LoginEventFilter) *gorm.DB {
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+	return query
+}
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"gpanel/global"
+	"gpanel/models"
+)
+
+type LoginThrottleRepo struct{}
+
+type ILoginThrottleRepo interface {
+	List() ([]models.LoginThrottle, error)
+	Get(kind, key string) (*models.LoginThrottle, error)
+	Save(throttle *models.LoginThrottle) error
+	Delete(kind, key string) error
+	DeleteByID(id uint) error
+	DeleteAll() error
+}
+
+func NewLoginThrottleRepo() ILoginThrottleRepo {
+	return &LoginThrottleRepo{}
+}
+
+func (r *LoginThrottleRepo) List() ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	err := global.DB.Order("last_failure_at desc").Find(&throttles).Error
+	return throttles, err
+}
+
+func (r *LoginThrottleRepo) Get(kind, key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	if err := global.DB.Where("kind = ? AND key = ?", kind, key).First(&throttle).Error; err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *LoginThrottleRepo) Save(throttle *models.LoginThrottle) error {
+	return global.DB.Save(throttle).Error
+}
+
+func (r *LoginThrottleRepo) Delete(kind, key string) error {
+	return global.DB.Where("kind = ? AND key = ?", kind, key).Delete(&models.LoginThrottle{}).Error
+}
+
+func (r *LoginThrottleRepo) DeleteByID(id uint) error {
+	return global.DB.Delete(&models.LoginThrottle{}, id).Error
+}
+
+func (r *LoginThrottleRepo) DeleteAll() error {
+	return global.DB.Where("1 = 1").Delete(&models.LoginThrottle{}).Error
+}
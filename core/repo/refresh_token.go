@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+type RefreshTokenRepo struct{}
+
+type IRefreshTokenRepo interface {
+	Create(token *models.RefreshToken) error
+	GetByHash(hash string) (*models.RefreshToken, error)
+	MarkUsed(id uint, at time.Time) (bool, error)
+	DeleteBySession(sessionID uint) error
+	DeleteOrphaned() error
+}
+
+func NewRefreshTokenRepo() IRefreshTokenRepo {
+	return &RefreshTokenRepo{}
+}
+
+func (r *RefreshTokenRepo) Create(token *models.RefreshToken) error {
+	return global.DB.Create(token).Error
+}
+
+func (r *RefreshTokenRepo) GetByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := global.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed 标记令牌已使用，返回 false 表示令牌已被并发请求使用过
+func (r *RefreshTokenRepo) MarkUsed(id uint, at time.Time) (bool, error) {
+	result := global.DB.Model(&models.RefreshToken{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *RefreshTokenRepo) DeleteBySession(sessionID uint) error {
+	return global.DB.Where("session_id = ?", sessionID).Delete(&models.RefreshToken{}).Error
+}
+
+// DeleteOrphaned 删除所属会话已被清理的令牌
+func (r *RefreshTokenRepo) DeleteOrphaned() error {
+	return global.DB.Where("session_id NOT IN (?)", global.DB.Model(&models.Session{}).Select("id")).
+		Delete(&models.RefreshToken{}).Error
+}
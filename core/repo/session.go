@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+// idleActiveCondition 未超过空闲过期时间，旧版本创建的会话没有空闲过期时间
+const idleActiveCondition = "(idle_expires_at > ? OR idle_expires_at IS NULL OR idle_expires_at = ?)"
+
+type SessionRepo struct{}
+
+type ISessionRepo interface {
+	Create(session *models.Session) error
+	GetByID(id uint) (*models.Session, error)
+	GetByJTI(jti string) (*models.Session, error)
+	Save(session *models.Session) error
+	ListActive(now time.Time) ([]models.Session, error)
+	ListActiveByUser(userID uint, now time.Time) ([]models.Session, error)
+	Revoke(id uint, at time.Time) error
+	RevokeByUser(userID uint, at time.Time) error
+	UpdateLastSeen(id uint, at time.Time) error
+	DeleteExpired(before time.Time) error
+}
+
+func NewSessionRepo() ISessionRepo {
+	return &SessionRepo{}
+}
+
+func (r *SessionRepo) Create(session *models.Session) error {
+	return global.DB.Create(session).Error
+}
+
+func (r *SessionRepo) GetByID(id uint) (*models.Session, error) {
+	var session models.Session
+	if err := global.DB.First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SessionRepo) GetByJTI(jti string) (*models.Session, error) {
+	var session models.Session
+	if err := global.DB.Where("jti = ?", jti).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SessionRepo) Save(session *models.Session) error {
+	return global.DB.Save(session).Error
+}
+
+func (r *SessionRepo) ListActive(now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := global.DB.Where("revoked_at IS NULL AND expires_at > ?", now).
+		Where(idleActiveCondition, now, time.Time{}).
+		Order("last_seen_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *SessionRepo) ListActiveByUser(userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := global.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Where(idleActiveCondition, now, time.Time{}).
+		Order("last_seen_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *SessionRepo) Revoke(id uint, at time.Time) error {
+	return global.DB.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at).Error
+}
+
+func (r *SessionRepo) RevokeByUser(userID uint, at time.Time) error {
+	return global.DB.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", at).Error
+}
+
+func (r *SessionRepo) UpdateLastSeen(id uint, at time.Time) error {
+	return global.DB.Model(&models.Session{}).Where("id = ?", id).UpdateColumn("last_seen_at", at).Error
+}
+
+func (r *SessionRepo) DeleteExpired(before time.Time) error {
+	return global.DB.Where("expires_at < ?", before).Delete(&models.Session{}).Error
+}
@@ -13,9 +13,11 @@ type ISettingRepo interface {
 	List() ([]models.Setting, error)
 	GetByKey(key string) (*models.Setting, error)
 	GetValueByKey(key string) (string, error)
-	Create(key, value, about string) error
+	Create(key, value, about string, secret bool) error
 	Update(key, value string) error
+	MarkSecret(key, value string) error
 	UpdateOrCreate(key, value, about string) error
+	SaveBatch(settings []models.Setting, deleted []string, revisions []models.SettingRevision) error
 	Delete(key string) error
 }
 
@@ -46,11 +48,12 @@ func (r *SettingRepo) GetValueByKey(key string) (string, error) {
 	return setting.Value, nil
 }
 
-func (r *SettingRepo) Create(key, value, about string) error {
+func (r *SettingRepo) Create(key, value, about string, secret bool) error {
 	setting := &models.Setting{
-		Key:   key,
-		Value: value,
-		About: about,
+		Key:    key,
+		Value:  value,
+		About:  about,
+		Secret: secret,
 	}
 	return global.DB.Create(setting).Error
 }
@@ -59,6 +62,12 @@ func (r *SettingRepo) Update(key, value string) error {
 	return global.DB.Model(&models.Setting{}).Where("key = ?", key).Update("value", value).Error
 }
 
+// MarkSecret 将设置项标记为敏感设置并写入加密后的值
+func (r *SettingRepo) MarkSecret(key, value string) error {
+	return global.DB.Model(&models.Setting{}).Where("key = ?", key).
+		Updates(map[string]interface{}{"value": value, "secret": true}).Error
+}
+
 func (r *SettingRepo) UpdateOrCreate(key, value, about string) error {
 	var setting models.Setting
 	result := global.DB.Where("key = ?", key).First(&setting)
@@ -71,6 +80,37 @@ func (r *SettingRepo) UpdateOrCreate(key, value, about string) error {
 	return global.DB.Model(&setting).Updates(map[string]interface{}{"value": value, "about": about}).Error
 }
 
+// SaveBatch 在同一事务中写入或删除设置项并保存修订记录，不存在的设置项会被创建，任一失败则全部回滚
+func (r *SettingRepo) SaveBatch(settings []models.Setting, deleted []string, revisions []models.SettingRevision) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		for _, setting := range settings {
+			result := tx.Model(&models.Setting{}).Where("key = ?", setting.Key).
+				Updates(map[string]interface{}{"value": setting.Value, "secret": setting.Secret})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				continue
+			}
+			setting := setting
+			if err := tx.Create(&setting).Error; err != nil {
+				return err
+			}
+		}
+		for _, key := range deleted {
+			if err := tx.Where("key = ?", key).Delete(&models.Setting{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(revisions) > 0 {
+			if err := tx.Create(&revisions).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (r *SettingRepo) Delete(key string) error {
 	return global.DB.Where("key = ?", key).Delete(&models.Setting{}).Error
 }
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"gorm.io/gorm"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+// SettingRevisionFilter 设置修订查询条件，零值表示不过滤
+type SettingRevisionFilter struct {
+	Key      string
+	Batch    string
+	Username string
+}
+
+type SettingRevisionRepo struct{}
+
+type ISettingRevisionRepo interface {
+	GetByID(id uint) (*models.SettingRevision, error)
+	List(filter SettingRevisionFilter, offset, limit int) ([]models.SettingRevision, int64, error)
+	ListByBatch(batch string) ([]models.SettingRevision, error)
+	ListAfter(id uint) ([]models.SettingRevision, error)
+}
+
+func NewSettingRevisionRepo() ISettingRevisionRepo {
+	return &SettingRevisionRepo{}
+}
+
+func (r *SettingRevisionRepo) GetByID(id uint) (*models.SettingRevision, error) {
+	var revision models.SettingRevision
+	if err := global.DB.First(&revision, id).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// List 按时间倒序查询
+func (r *SettingRevisionRepo) List(filter SettingRevisionFilter, offset, limit int) ([]models.SettingRevision, int64, error) {
+	query := applySettingRevisionFilter(global.DB.Model(&models.SettingRevision{}), filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var revisions []models.SettingRevision
+	if err := query.Order("id desc").Offset(offset).Limit(limit).Find(&revisions).Error; err != nil {
+		return nil, 0, err
+	}
+	return revisions, total, nil
+}
+
+func (r *SettingRevisionRepo) ListByBatch(batch string) ([]models.SettingRevision, error) {
+	var revisions []models.SettingRevision
+	err := global.DB.Where("batch = ?", batch).Order("id").Find(&revisions).Error
+	return revisions, err
+}
+
+// ListAfter 按时间顺序查询指定修订之后的全部修订
+func (r *SettingRevisionRepo) ListAfter(id uint) ([]models.SettingRevision, error) {
+	var revisions []models.SettingRevision
+	err := global.DB.Where("id > ?", id).Order("id").Find(&revisions).Error
+	return revisions, err
+}
+
+func applySettingRevisionFilter(query *gorm.DB, filter SettingRevisionFilter) *gorm.DB {
+	if filter.Key != "" {
+		query = query.Where("key = ?", filter.Key)
+	}
+	if filter.Batch != "" {
+		query = query.Where("batch = ?", filter.Batch)
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	return query
+}
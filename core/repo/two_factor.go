@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"gpanel/global"
+	"gpanel/models"
+)
+
+type TwoFactorRepo struct{}
+
+type ITwoFactorRepo interface {
+	List() ([]models.TwoFactor, error)
+	GetByUsername(username string) (*models.TwoFactor, error)
+	Save(twoFactor *models.TwoFactor) error
+	Delete(username string) error
+	Rename(oldUsername, newUsername string) error
+}
+
+func NewTwoFactorRepo() ITwoFactorRepo {
+	return &TwoFactorRepo{}
+}
+
+func (r *TwoFactorRepo) List() ([]models.TwoFactor, error) {
+	var twoFactors []models.TwoFactor
+	err := global.DB.Find(&twoFactors).Error
+	return twoFactors, err
+}
+
+func (r *TwoFactorRepo) GetByUsername(username string) (*models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+	err := global.DB.Where("username = ?", username).First(&twoFactor).Error
+	if err != nil {
+		return nil, err
+	}
+	return &twoFactor, nil
+}
+
+func (r *TwoFactorRepo) Save(twoFactor *models.TwoFactor) error {
+	return global.DB.Save(twoFactor).Error
+}
+
+func (r *TwoFactorRepo) Delete(username string) error {
+	return global.DB.Where("username = ?", username).Delete(&models.TwoFactor{}).Error
+}
+
+func (r *TwoFactorRepo) Rename(oldUsername, newUsername string) error {
+	return global.DB.Model(&models.TwoFactor{}).Where("username = ?", oldUsername).Update("username", newUsername).Error
+}
@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+// errNoActiveAdmin 用于回滚会导致没有启用管理员的事务
+var errNoActiveAdmin = errors.New("no active admin left")
+
+type UserRepo struct{}
+
+type IUserRepo interface {
+	List() ([]models.User, error)
+	GetByID(id uint) (*models.User, error)
+	GetByUsername(username string) (*models.User, error)
+	GetByOIDCSubject(issuer, subject string) (*models.User, error)
+	Create(user *models.User) error
+	Save(user *models.User) error
+	SaveKeepingAdmin(user *models.User) (bool, error)
+	Delete(id uint) error
+	DeleteKeepingAdmin(id uint) (bool, error)
+	Count() (int64, error)
+}
+
+func NewUserRepo() IUserRepo {
+	return &UserRepo{}
+}
+
+func (r *UserRepo) List() ([]models.User, error) {
+	var users []models.User
+	err := global.DB.Order("id").Find(&users).Error
+	return users, err
+}
+
+func (r *UserRepo) GetByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := global.DB.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepo) GetByUsername(username string) (*models.User, error) {
+	var user models.User
+	if err := global.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepo) GetByOIDCSubject(issuer, subject string) (*models.User, error) {
+	var user models.User
+	if err := global.DB.Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepo) Create(user *models.User) error {
+	return global.DB.Create(user).Error
+}
+
+func (r *UserRepo) Save(user *models.User) error {
+	return global.DB.Save(user).Error
+}
+
+// SaveKeepingAdmin 在事务中保存用户，保存后没有启用的管理员时回滚并返回 false
+func (r *UserRepo) SaveKeepingAdmin(user *models.User) (bool, error) {
+	return keepingAdmin(func(tx *gorm.DB) error {
+		return tx.Save(user).Error
+	})
+}
+
+func (r *UserRepo) Delete(id uint) error {
+	return global.DB.Delete(&models.User{}, id).Error
+}
+
+// DeleteKeepingAdmin 在事务中删除用户，删除后没有启用的管理员时回滚并返回 false
+func (r *UserRepo) DeleteKeepingAdmin(id uint) (bool, error) {
+	return keepingAdmin(func(tx *gorm.DB) error {
+		return tx.Delete(&models.User{}, id).Error
+	})
+}
+
+// keepingAdmin 执行写入后在同一事务中统计启用的管理员，检查和写入之间不会被并发修改穿插
+func keepingAdmin(write func(tx *gorm.DB) error) (bool, error) {
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+		count, err := countActiveAdmins(tx)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errNoActiveAdmin
+		}
+		return nil
+	})
+	if errors.Is(err, errNoActiveAdmin) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *UserRepo) Count() (int64, error) {
+	var count int64
+	err := global.DB.Model(&models.User{}).Count(&count).Error
+	return count, err
+}
+
+func countActiveAdmins(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&models.User{}).
+		Where("role = ? AND disabled = ?", models.RoleAdmin, false).
+		Count(&count).Error
+	return count, err
+}
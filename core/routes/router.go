@@ -3,6 +3,7 @@ package routes
 import (
 	"gpanel/controllers"
 	"gpanel/middleware"
+	"gpanel/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,32 +12,119 @@ func SetupRouter(r *gin.Engine) {
 	api := r.Group("/api")
 	{
 		v1 := api.Group("/v1")
+		v1.Use(middleware.Audit())
 		{
+			// 权限校验
+			systemRead := middleware.RequirePermission(models.PermSystemRead)
+			settingsRead := middleware.RequirePermission(models.PermSettingsRead)
+			settingsWrite := middleware.RequirePermission(models.PermSettingsWrite)
+			serverRestart := middleware.RequirePermission(models.PermServerRestart)
+			securityManage := middleware.RequirePermission(models.PermSecurityManage)
+
 			v1.GET("/health", controllers.HealthCheck)
 			v1.POST("/auth/login", controllers.Login)
-			v1.GET("/system/info", middleware.Auth(), controllers.GetSystemInfo)
-			v1.GET("/system/current", middleware.Auth(), controllers.GetCurrentInfo)
-			v1.GET("/system/version", middleware.Auth(), controllers.GetVersion)
-			v1.GET("/config", middleware.Auth(), controllers.GetConfig)
-			v1.POST("/config", middleware.Auth(), controllers.UpdateConfig)
-			v1.GET("/config/initialized", middleware.Auth(), controllers.CheckConfigInitialized)
-			v1.POST("/server/restart", middleware.Auth(), controllers.RestartServer)
+			v1.POST("/auth/login/2fa", controllers.LoginTwoFactor)
+			v1.POST("/auth/refresh", controllers.RefreshToken)
+
+			// OIDC 单点登录
+			oidcController := controllers.NewOIDCController()
+			v1.GET("/auth/oidc/config", oidcController.GetConfig)
+			v1.GET("/auth/oidc/login", oidcController.Login)
+			v1.GET("/auth/oidc/callback", oidcController.Callback)
+
+			v1.GET("/auth/me", middleware.Auth(), controllers.GetCurrentUser)
+			v1.POST("/auth/password", middleware.Auth(), middleware.RequireSession(), controllers.ChangePassword)
+			v1.POST("/auth/keys/rotate", middleware.Auth(), securityManage, controllers.RotateJWTKey)
+			v1.GET("/system/info", middleware.Auth(), systemRead, controllers.GetSystemInfo)
+			v1.GET("/system/current", middleware.Auth(), systemRead, controllers.GetCurrentInfo)
+			v1.GET("/system/version", middleware.Auth(), systemRead, controllers.GetVersion)
+			v1.GET("/config", middleware.Auth(), settingsRead, controllers.GetConfig)
+			v1.POST("/config", middleware.Auth(), settingsWrite, controllers.UpdateConfig)
+			v1.GET("/config/initialized", middleware.Auth(), settingsRead, controllers.CheckConfigInitialized)
+			v1.GET("/config/sources", middleware.Auth(), settingsRead, controllers.GetConfigSources)
+			v1.POST("/server/restart", middleware.Auth(), serverRestart, controllers.RestartServer)
+
+			// 会话管理 API
+			sessionController := controllers.NewSessionController()
+			v1.POST("/auth/logout", middleware.Auth(), middleware.RequireSession(), sessionController.Logout)
+			v1.GET("/auth/sessions", middleware.Auth(), middleware.RequireSession(), sessionController.ListMySessions)
+			v1.DELETE("/auth/sessions/:id", middleware.Auth(), middleware.RequireSession(), sessionController.RevokeMySession)
+			v1.GET("/sessions", middleware.Auth(), securityManage, sessionController.ListSessions)
+			v1.DELETE("/sessions/:id", middleware.Auth(), securityManage, sessionController.TerminateSession)
+
+			// 登录锁定管理 API
+			lockoutController := controllers.NewLockoutController()
+			lockouts := v1.Group("/security/lockouts", middleware.Auth(), securityManage)
+			{
+				lockouts.GET("", lockoutController.ListLockouts)
+				lockouts.DELETE("", lockoutController.ClearAllLockouts)
+				lockouts.DELETE("/:id", lockoutController.ClearLockout)
+			}
+
+			// 双因素认证 API
+			twoFactor := v1.Group("/auth/2fa", middleware.Auth(), middleware.RequireSession())
+			{
+				twoFactor.GET("/status", controllers.GetTwoFactorStatus)
+				twoFactor.POST("/setup", controllers.SetupTwoFactor)
+				twoFactor.POST("/enable", controllers.EnableTwoFactor)
+				twoFactor.POST("/disable", controllers.DisableTwoFactor)
+				twoFactor.POST("/recovery-codes", controllers.RegenerateRecoveryCodes)
+			}
+
+			// 用户管理 API
+			userController := controllers.NewUserController()
+			users := v1.Group("/users", middleware.Auth(), middleware.RequirePermission(models.PermUsersManage))
+			{
+				users.GET("", userController.ListUsers)
+				users.POST("", userController.CreateUser)
+				users.GET("/:id", userController.GetUser)
+				users.PUT("/:id", userController.UpdateUser)
+				users.DELETE("/:id", userController.DeleteUser)
+			}
+
+			// 个人访问令牌 API
+			apiTokenController := controllers.NewAPITokenController()
+			tokens := v1.Group("/tokens", middleware.Auth(), middleware.RequireSession())
+			{
+				tokens.GET("", apiTokenController.ListTokens)
+				tokens.POST("", apiTokenController.CreateToken)
+				tokens.DELETE("/:id", apiTokenController.RevokeToken)
+			}
 
 			// 系统设置 API
 			settingController := controllers.NewSettingController()
 			settings := v1.Group("/settings")
 			{
-				settings.GET("", middleware.Auth(), settingController.GetAllSettings)
-				settings.GET("/system", middleware.Auth(), settingController.GetSystemSettings)
-				settings.POST("/system", middleware.Auth(), settingController.UpdateSystemSettings)
-				settings.GET("/:key", middleware.Auth(), settingController.GetSettingByKey)
-				settings.POST("", middleware.Auth(), settingController.CreateSetting)
-				settings.PUT("", middleware.Auth(), settingController.UpdateSetting)
-				settings.DELETE("/:key", middleware.Auth(), settingController.DeleteSetting)
+				settings.GET("", middleware.Auth(), settingsRead, settingController.GetAllSettings)
+				settings.GET("/system", middleware.Auth(), settingsRead, settingController.GetSystemSettings)
+				settings.GET("/schema", middleware.Auth(), settingsRead, settingController.GetSettingSchema)
+				settings.POST("/system", middleware.Auth(), settingsWrite, settingController.UpdateSystemSettings)
+				settings.GET("/history", middleware.Auth(), settingsRead, settingController.ListSettingHistory)
+				settings.GET("/export", middleware.Auth(), settingsWrite, settingController.ExportSettings)
+				settings.POST("/import", middleware.Auth(), settingsWrite, settingController.ImportSettings)
+				settings.POST("/rollback", middleware.Auth(), settingsWrite, settingController.RollbackSettings)
+				settings.GET("/:key/history", middleware.Auth(), settingsRead, settingController.ListSettingKeyHistory)
+				settings.GET("/:key", middleware.Auth(), settingsRead, settingController.GetSettingByKey)
+				settings.POST("", middleware.Auth(), settingsWrite, settingController.CreateSetting)
+				settings.PUT("", middleware.Auth(), settingsWrite, settingController.UpdateSetting)
+				settings.DELETE("/:key", middleware.Auth(), settingsWrite, settingController.DeleteSetting)
+			}
+
+			// 登录记录
+			loginEventController := controllers.NewLoginEventController()
+			v1.GET("/auth/login-history", middleware.Auth(), middleware.RequireSession(), loginEventController.ListMyLoginEvents)
+			v1.GET("/login-events", middleware.Auth(), middleware.RequirePermission(models.PermAuditRead), loginEventController.ListLoginEvents)
+
+			// 审计日志 API
+			auditController := controllers.NewAuditController()
+			audit := v1.Group("/audit", middleware.Auth(), middleware.RequirePermission(models.PermAuditRead))
+			{
+				audit.GET("", auditController.ListAuditLogs)
+				audit.GET("/export", auditController.ExportAuditLogs)
 			}
 
 			// 配置热重载 API
-			v1.POST("/config/reload", middleware.Auth(), controllers.ReloadConfig)
+			v1.POST("/config/reload", middleware.Auth(), settingsWrite, controllers.ReloadConfig)
 		}
 	}
-}
\ No newline at end of file
+}
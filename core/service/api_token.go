@@ -0,0 +1,140 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"gpanel/models"
+	"gpanel/repo"
+)
+
+// APITokenPrefix 个人访问令牌前缀，用于和 JWT 区分
+const APITokenPrefix = "gpat_"
+
+// apiTokenTouchInterval 最近使用时间的最小更新间隔，避免每个请求都写库
+const apiTokenTouchInterval = time.Minute
+
+var (
+	ErrAPITokenNotFound = errors.New("api token not found")
+	ErrAPITokenInvalid  = errors.New("api token is invalid, expired or revoked")
+	ErrInvalidScope     = errors.New("invalid scope")
+	ErrAPITokenName     = errors.New("api token name is required")
+)
+
+type APITokenService struct{}
+
+type IAPITokenService interface {
+	Create(user *models.User, name string, scopes []string, expiresAt *time.Time) (string, *models.APIToken, error)
+	List(userID uint) ([]models.APIToken, error)
+	ListAll() ([]models.APIToken, error)
+	Revoke(id uint, userID uint, anyUser bool) error
+	Authenticate(raw string) (*models.APIToken, *models.User, error)
+}
+
+func NewAPITokenService() IAPITokenService {
+	return &APITokenService{}
+}
+
+var apiTokenRepo repo.IAPITokenRepo = repo.NewAPITokenRepo()
+
+// IsAPIToken 判断 Bearer 凭据是否为个人访问令牌
+func IsAPIToken(raw string) bool {
+	return strings.HasPrefix(raw, APITokenPrefix)
+}
+
+func hashAPIToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create 创建令牌，返回的明文令牌只会出现这一次。scope 不能超出用户角色的权限
+func (s *APITokenService) Create(user *models.User, name string, scopes []string, expiresAt *time.Time) (string, *models.APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, ErrAPITokenName
+	}
+	if len(scopes) == 0 {
+		return "", nil, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !models.RoleHasPermission(user.Role, scope) {
+			return "", nil, ErrInvalidScope
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	raw := APITokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	token := &models.APIToken{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    raw[:len(APITokenPrefix)+6],
+		TokenHash: hashAPIToken(raw),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := apiTokenRepo.Create(token); err != nil {
+		return "", nil, err
+	}
+	return raw, token, nil
+}
+
+func (s *APITokenService) List(userID uint) ([]models.APIToken, error) {
+	return apiTokenRepo.ListByUser(userID)
+}
+
+func (s *APITokenService) ListAll() ([]models.APIToken, error) {
+	return apiTokenRepo.List()
+}
+
+// Revoke 吊销令牌，anyUser 为 true 时可吊销其他用户的令牌
+func (s *APITokenService) Revoke(id uint, userID uint, anyUser bool) error {
+	token, err := apiTokenRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAPITokenNotFound
+		}
+		return err
+	}
+	if !anyUser && token.UserID != userID {
+		return ErrAPITokenNotFound
+	}
+	return apiTokenRepo.Revoke(id, time.Now())
+}
+
+// Authenticate 校验令牌并返回所属用户
+func (s *APITokenService) Authenticate(raw string) (*models.APIToken, *models.User, error) {
+	token, err := apiTokenRepo.GetByHash(hashAPIToken(raw))
+	if err != nil {
+		return nil, nil, ErrAPITokenInvalid
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && now.After(*token.ExpiresAt)) {
+		return nil, nil, ErrAPITokenInvalid
+	}
+
+	user, err := userRepo.GetByID(token.UserID)
+	if err != nil || user.Disabled {
+		return nil, nil, ErrAPITokenInvalid
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > apiTokenTouchInterval {
+		if err := apiTokenRepo.UpdateLastUsed(token.ID, now); err != nil {
+			log.Printf("Failed to update api token last used time: %v", err)
+		}
+		token.LastUsedAt = &now
+	}
+	return token, user, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/repo"
+)
+
+// auditCleanupInterval 清理过期审计日志的最小间隔
+const auditCleanupInterval = time.Hour
+
+// maxAuditExport 单次导出的最大条数
+const maxAuditExport = 100000
+
+// redactedValue 敏感设置在审计日志中的占位值
+const redactedValue = "******"
+
+type AuditService struct{}
+
+type IAuditService interface {
+	Record(entry *models.AuditLog) error
+	List(filter repo.AuditLogFilter, page, pageSize int) ([]models.AuditLog, int64, error)
+	Export(filter repo.AuditLogFilter) ([]models.AuditLog, error)
+}
+
+func NewAuditService() IAuditService {
+	return &AuditService{}
+}
+
+var auditLogRepo repo.IAuditLogRepo = repo.NewAuditLogRepo()
+
+var (
+	auditCleanupMu   sync.Mutex
+	lastAuditCleanup time.Time
+)
+
+// Record 写入审计日志，并按保留天数定期清理过期记录
+func (s *AuditService) Record(entry *models.AuditLog) error {
+	if err := auditLogRepo.Create(entry); err != nil {
+		return err
+	}
+
+	auditCleanupMu.Lock()
+	defer auditCleanupMu.Unlock()
+
+	now := time.Now()
+	if now.Sub(lastAuditCleanup) < auditCleanupInterval {
+		return nil
+	}
+	lastAuditCleanup = now
+
+	retentionDays := 90
+	if global.ConfigCacheInstance != nil {
+		retentionDays = global.ConfigCacheInstance.GetAuditRetentionDays()
+	}
+	if retentionDays > 0 {
+		if err := auditLogRepo.DeleteBefore(now.AddDate(0, 0, -retentionDays)); err != nil {
+			log.Printf("Failed to clean up audit logs: %v", err)
+		}
+	}
+	return nil
+}
+
+// List 分页查询，page 从 1 开始
+func (s *AuditService) List(filter repo.AuditLogFilter, page, pageSize int) ([]models.AuditLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	return auditLogRepo.List(filter, (page-1)*pageSize, pageSize)
+}
+
+func (s *AuditService) Export(filter repo.AuditLogFilter) ([]models.AuditLog, error) {
+	logs, _, err := auditLogRepo.List(filter, 0, maxAuditExport)
+	return logs, err
+}
+
+// SettingChanges 计算设置修改前后的差异，凭据和密钥只记录是否修改
+func SettingChanges(updates map[string]string) map[string]models.AuditChange {
+	changes := make(map[string]models.AuditChange)
+	for key, after := range updates {
+		before, exists := "", false
+		if global.ConfigCacheInstance != nil {
+			before, exists = global.ConfigCacheInstance.Get(key)
+		}
+		if !exists {
+			before, _ = settingRepo.GetValueByKey(key)
+		}
+		if before == after {
+			continue
+		}
+
+		if IsSensitiveKey(key) {
+			before, after = redactedValue, redactedValue
+		}
+		changes[key] = models.AuditChange{Before: before, After: after}
+	}
+	return changes
+}
+
+// RedactedSettingChanges 计算设置差异并隐藏所有值，用于新建的敏感设置项
+func RedactedSettingChanges(updates map[string]string) map[string]models.AuditChange {
+	changes := SettingChanges(updates)
+	for key := range changes {
+		changes[key] = models.AuditChange{Before: redactedValue, After: redactedValue}
+	}
+	return changes
+}
+
+// BundleSettingChanges 计算导入设置包的设置差异，导出包中标记为敏感的自定义设置项隐藏值
+func BundleSettingChanges(values map[string]string, custom map[string]BundleCustomSetting) map[string]models.AuditChange {
+	changes := SettingChanges(values)
+	for key := range changes {
+		if custom[key].Secret {
+			changes[key] = models.AuditChange{Before: redactedValue, After: redactedValue}
+		}
+	}
+	return changes
+}
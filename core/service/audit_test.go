@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+
+	"gpanel/global"
+)
+
+func TestSettingChangesRedactsCredentials(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{"ServerAddress": "old.example.com"})
+	language, _ := global.ConfigCacheInstance.Get("Language")
+
+	changes := SettingChanges(map[string]string{
+		"ServerAddress": "new.example.com",
+		"Language":      language,
+		"PanelPassword": "New-Secret-123",
+	})
+
+	tests := []struct {
+		key        string
+		wantChange bool
+		wantBefore string
+		wantAfter  string
+	}{
+		{"ServerAddress", true, "old.example.com", "new.example.com"},
+		{"Language", false, "", ""},
+		{"PanelPassword", true, redactedValue, redactedValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			change, ok := changes[tt.key]
+			if ok != tt.wantChange {
+				t.Fatalf("changed = %v, want %v", ok, tt.wantChange)
+			}
+			if change.Before != tt.wantBefore || change.After != tt.wantAfter {
+				t.Fatalf("change = %+v, want %q -> %q", change, tt.wantBefore, tt.wantAfter)
+			}
+		})
+	}
+}
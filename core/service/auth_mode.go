@@ -0,0 +1,42 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"gpanel/global"
+)
+
+const (
+	AuthModeToken  = "token"
+	AuthModeCookie = "cookie"
+
+	// AccessCookieName cookie 模式下保存访问 token 的 HttpOnly cookie
+	AccessCookieName = "gpanel_token"
+	// CSRFCookieName 双重提交 CSRF token，前端读取后放入 CSRFHeaderName 请求头
+	CSRFCookieName = "gpanel_csrf"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CookieAuthEnabled 是否使用 cookie 保存访问 token
+func CookieAuthEnabled() bool {
+	return global.ConfigCacheInstance != nil && global.ConfigCacheInstance.GetAuthMode() == AuthModeCookie
+}
+
+// NewCSRFToken 生成随机 CSRF token
+func NewCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ValidCSRFToken 校验请求头中的 CSRF token 与 cookie 一致
+func ValidCSRFToken(cookieToken, headerToken string) bool {
+	if cookieToken == "" || headerToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) == 1
+}
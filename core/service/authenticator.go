@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gpanel/models"
+)
+
+// Authenticator 用户名密码登录的认证后端。
+// 返回 ErrInvalidCredentials 表示该后端无法认证此用户，交给下一个后端处理
+type Authenticator interface {
+	Name() string
+	Enabled() bool
+	Authenticate(username, password string) (*models.User, error)
+}
+
+// authenticators 按顺序尝试的认证后端，本地账户始终作为最后的兜底
+var authenticators = []Authenticator{
+	&LDAPAuthenticator{},
+	&LocalAuthenticator{},
+}
+
+// AuthenticateLogin 依次尝试已启用的认证后端，外部后端不可用时回退到本地账户。
+// 外部后端因同名账户而跳过且最终认证失败时记录日志，便于排查 LDAP 用户无法登录的原因
+func AuthenticateLogin(username, password string) (*models.User, error) {
+	var skipped []string
+	for _, authenticator := range authenticators {
+		if !authenticator.Enabled() {
+			continue
+		}
+
+		user, err := authenticator.Authenticate(username, password)
+		if err == nil {
+			return user, nil
+		}
+		if errors.Is(err, ErrLDAPLocalConflict) {
+			skipped = append(skipped, fmt.Sprintf("%s authentication skipped for user %s: %v", authenticator.Name(), username, err))
+			continue
+		}
+		if !errors.Is(err, ErrInvalidCredentials) {
+			log.Printf("%s authentication failed for user %s: %v", authenticator.Name(), username, err)
+		}
+	}
+	for _, message := range skipped {
+		log.Print(message)
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// LocalAuthenticator 使用本地账户密码认证
+type LocalAuthenticator struct{}
+
+func (a *LocalAuthenticator) Name() string {
+	return models.UserSourceLocal
+}
+
+func (a *LocalAuthenticator) Enabled() bool {
+	return true
+}
+
+func (a *LocalAuthenticator) Authenticate(username, password string) (*models.User, error) {
+	return NewUserService().Authenticate(username, password)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+
+	"gpanel/global"
+	"gpanel/utils"
+)
+
+var (
+	ErrBindDomainEmpty   = errors.New("ServerAddress must contain at least one domain when domain binding is enabled")
+	ErrDomainSelfLockout = errors.New("domain binding would block the host you are currently using")
+)
+
+// BoundDomains 返回绑定的域名列表，未开启域名绑定时返回 nil
+func BoundDomains(bindDomain bool, serverAddress string) []string {
+	if !bindDomain {
+		return nil
+	}
+	return utils.ParseDomainList(serverAddress)
+}
+
+// ValidateDomainBindingUpdate 校验开启域名绑定后，当前访问使用的域名仍然可以访问面板
+func ValidateDomainBindingUpdate(updates map[string]string, host string) error {
+	bind, bindChanged := updates["BindDomain"]
+	address, addressChanged := updates["ServerAddress"]
+	if !bindChanged && !addressChanged {
+		return nil
+	}
+
+	if global.ConfigCacheInstance != nil {
+		if !bindChanged && global.ConfigCacheInstance.GetBindDomain() {
+			bind = "true"
+		}
+		if !addressChanged {
+			address = global.ConfigCacheInstance.GetServerAddress()
+		}
+	}
+
+	if bind != "true" {
+		return nil
+	}
+	domains := BoundDomains(true, address)
+	if len(domains) == 0 {
+		return ErrBindDomainEmpty
+	}
+	if !utils.MatchDomain(domains, host) {
+		return ErrDomainSelfLockout
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"gpanel/global"
+)
+
+// entranceSession 通过安全入口签发的 sessionkey，仅保存在内存中，重启后需重新从安全入口进入
+type entranceSession struct {
+	ip        string
+	userAgent string
+	expiresAt time.Time
+}
+
+type EntranceSessionService struct{}
+
+type IEntranceSessionService interface {
+	Issue(ip, userAgent string) (string, time.Duration, error)
+	Validate(key, ip, userAgent string) (time.Duration, bool)
+	Clear()
+}
+
+func NewEntranceSessionService() IEntranceSessionService {
+	return &EntranceSessionService{}
+}
+
+const (
+	// maxEntranceSessions 内存中最多保留的 sessionkey 数量，超出时淘汰最早过期的
+	maxEntranceSessions = 10000
+	// entranceSweepInterval 清理过期 sessionkey 的最小间隔
+	entranceSweepInterval = time.Minute
+)
+
+var (
+	entranceSessionsMu sync.Mutex
+	entranceSessions   = make(map[string]entranceSession)
+	lastEntranceSweep  time.Time
+)
+
+func entranceSessionTimeout() time.Duration {
+	timeout := 1800
+	if global.ConfigCacheInstance != nil {
+		timeout = global.ConfigCacheInstance.GetEntranceSessionTimeout()
+	}
+	if timeout <= 0 {
+		timeout = 1800
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// Issue 签发新的 sessionkey，返回 key 和有效期
+func (s *EntranceSessionService) Issue(ip, userAgent string) (string, time.Duration, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", 0, err
+	}
+	key := hex.EncodeToString(b)
+	ttl := entranceSessionTimeout()
+
+	entranceSessionsMu.Lock()
+	defer entranceSessionsMu.Unlock()
+
+	now := time.Now()
+	sweepEntranceSessions(now, true)
+	if len(entranceSessions) >= maxEntranceSessions {
+		evictOldestEntranceSession()
+	}
+	entranceSessions[key] = entranceSession{
+		ip:        ip,
+		userAgent: userAgent,
+		expiresAt: now.Add(ttl),
+	}
+	return key, ttl, nil
+}
+
+// Validate 校验 sessionkey 已签发且未过期，开启绑定时还需匹配签发时的 IP 和 User-Agent。
+// 校验通过后有效期从当前时间重新计算，返回新的有效期供调用方续期 cookie
+func (s *EntranceSessionService) Validate(key, ip, userAgent string) (time.Duration, bool) {
+	if key == "" {
+		return 0, false
+	}
+	ttl := entranceSessionTimeout()
+
+	entranceSessionsMu.Lock()
+	defer entranceSessionsMu.Unlock()
+
+	now := time.Now()
+	sweepEntranceSessions(now, false)
+
+	session, ok := entranceSessions[key]
+	if !ok {
+		return 0, false
+	}
+	if now.After(session.expiresAt) {
+		delete(entranceSessions, key)
+		return 0, false
+	}
+
+	if global.ConfigCacheInstance != nil {
+		if global.ConfigCacheInstance.GetEntranceBindIP() && session.ip != ip {
+			return 0, false
+		}
+		if global.ConfigCacheInstance.GetEntranceBindUserAgent() && session.userAgent != userAgent {
+			return 0, false
+		}
+	}
+
+	session.expiresAt = now.Add(ttl)
+	entranceSessions[key] = session
+	return ttl, true
+}
+
+// sweepEntranceSessions 删除已过期的 sessionkey，force 为 false 时按 entranceSweepInterval 限制频率，调用方需持有锁
+func sweepEntranceSessions(now time.Time, force bool) {
+	if !force && now.Sub(lastEntranceSweep) < entranceSweepInterval {
+		return
+	}
+	lastEntranceSweep = now
+	for k, session := range entranceSessions {
+		if now.After(session.expiresAt) {
+			delete(entranceSessions, k)
+		}
+	}
+}
+
+// evictOldestEntranceSession 淘汰最早过期的 sessionkey，调用方需持有锁
+func evictOldestEntranceSession() {
+	var oldestKey string
+	var oldest time.Time
+	for k, session := range entranceSessions {
+		if oldestKey == "" || session.expiresAt.Before(oldest) {
+			oldestKey, oldest = k, session.expiresAt
+		}
+	}
+	delete(entranceSessions, oldestKey)
+}
+
+// Clear 清除所有 sessionkey，安全入口变更时调用
+func (s *EntranceSessionService) Clear() {
+	entranceSessionsMu.Lock()
+	defer entranceSessionsMu.Unlock()
+
+	entranceSessions = make(map[string]entranceSession)
+}
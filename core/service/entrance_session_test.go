@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntranceSessionSlidingExpiry(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{"EntranceSessionTimeout": "600"})
+	sessions := NewEntranceSessionService()
+	sessions.Clear()
+
+	key, ttl, err := sessions.Issue("10.0.0.1", "ua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl != 10*time.Minute {
+		t.Fatalf("ttl = %s, want 10m", ttl)
+	}
+
+	// 临近过期的 sessionkey 校验通过后应重新获得完整有效期
+	entranceSessionsMu.Lock()
+	session := entranceSessions[key]
+	session.expiresAt = time.Now().Add(time.Second)
+	entranceSessions[key] = session
+	entranceSessionsMu.Unlock()
+
+	renewed, ok := sessions.Validate(key, "10.0.0.1", "ua")
+	if !ok || renewed != ttl {
+		t.Fatalf("Validate = %s, %v; want %s, true", renewed, ok, ttl)
+	}
+	entranceSessionsMu.Lock()
+	expiresAt := entranceSessions[key].expiresAt
+	entranceSessionsMu.Unlock()
+	if time.Until(expiresAt) < ttl-time.Minute {
+		t.Fatalf("expiry was not extended: %s left", time.Until(expiresAt))
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		ip   string
+		ua   string
+		want bool
+	}{
+		{"valid", key, "10.0.0.1", "ua", true},
+		{"empty key", "", "10.0.0.1", "ua", false},
+		{"unknown key", "deadbeef", "10.0.0.1", "ua", false},
+		{"other ip", key, "10.0.0.2", "ua", false},
+		{"other user agent", key, "10.0.0.1", "other", false},
+	}
+	setTestSettings(t, map[string]string{"EntranceBindIP": "true", "EntranceBindUserAgent": "true"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := sessions.Validate(tt.key, tt.ip, tt.ua); ok != tt.want {
+				t.Fatalf("Validate = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntranceSessionExpiredAndBounded(t *testing.T) {
+	setupTestDB(t)
+	sessions := NewEntranceSessionService()
+	sessions.Clear()
+
+	key, _, err := sessions.Issue("10.0.0.1", "ua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entranceSessionsMu.Lock()
+	session := entranceSessions[key]
+	session.expiresAt = time.Now().Add(-time.Second)
+	entranceSessions[key] = session
+	entranceSessionsMu.Unlock()
+	if _, ok := sessions.Validate(key, "10.0.0.1", "ua"); ok {
+		t.Fatal("expired sessionkey should be rejected")
+	}
+
+	for i := 0; i < maxEntranceSessions+10; i++ {
+		if _, _, err := sessions.Issue("10.0.0.1", "ua"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entranceSessionsMu.Lock()
+	count := len(entranceSessions)
+	entranceSessionsMu.Unlock()
+	if count != maxEntranceSessions {
+		t.Fatalf("kept %d sessions, want %d", count, maxEntranceSessions)
+	}
+	sessions.Clear()
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"gpanel/models"
+)
+
+// rolePriority 多个组映射到不同角色时取权限最高的角色
+var rolePriority = map[string]int{
+	models.RoleReadOnly: 1,
+	models.RoleOperator: 2,
+	models.RoleAdmin:    3,
+}
+
+// mapExternalRole 按 JSON 映射（组或声明值 -> 角色）计算角色，不区分大小写，未匹配时使用默认角色
+func mapExternalRole(mappingJSON string, values []string, defaultRole string) (string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(mappingJSON) != "" {
+		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
+			return "", fmt.Errorf("invalid role mapping: %w", err)
+		}
+	}
+
+	normalized := make(map[string]string, len(mapping))
+	for value, role := range mapping {
+		normalized[strings.ToLower(value)] = role
+	}
+
+	role := ""
+	for _, value := range values {
+		mapped, ok := normalized[strings.ToLower(value)]
+		if ok && models.IsValidRole(mapped) && rolePriority[mapped] > rolePriority[role] {
+			role = mapped
+		}
+	}
+	if role == "" {
+		role = defaultRole
+	}
+	if !models.IsValidRole(role) {
+		return "", ErrInvalidCredentials
+	}
+	return role, nil
+}
+
+// provisionExternalUser 外部认证的用户首次登录时创建账户，之后每次登录同步角色。
+// 同名的其他来源账户不允许登录，避免外部系统中的同名用户接管本地管理员
+func provisionExternalUser(source, username, role string) (*models.User, error) {
+	user, err := userRepo.GetByUsername(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = &models.User{
+			Username: username,
+			Role:     role,
+			Source:   source,
+		}
+		if err := userRepo.Create(user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Source != source || user.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+	return syncExternalRole(user, role)
+}
+
+// provisionOIDCUser 按 iss 和 sub 查找单点登录用户，首次登录时以用户名声明创建账户并记录 iss 和 sub。
+// 用户名声明可被用户修改且不保证唯一，不能用来匹配已有账户：用户名已被占用时拒绝登录
+func provisionOIDCUser(issuer, subject, username, role string) (*models.User, error) {
+	user, err := userRepo.GetByOIDCSubject(issuer, subject)
+	if err == nil {
+		if user.Source != models.UserSourceOIDC || user.Disabled {
+			return nil, ErrInvalidCredentials
+		}
+		return syncExternalRole(user, role)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if _, err := userRepo.GetByUsername(username); err == nil {
+		log.Printf("OIDC login for %s (sub %s) rejected: username already taken", username, subject)
+		return nil, ErrInvalidCredentials
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	user = &models.User{
+		Username:    username,
+		Role:        role,
+		Source:      models.UserSourceOIDC,
+		OIDCIssuer:  issuer,
+		OIDCSubject: subject,
+	}
+	if err := userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncExternalRole 同步外部认证用户的角色，不允许因组变更导致失去最后一个管理员
+func syncExternalRole(user *models.User, role string) (*models.User, error) {
+	if user.Role == role {
+		return user, nil
+	}
+	wasAdmin := user.Role == models.RoleAdmin
+	user.Role = role
+	if wasAdmin {
+		kept, err := userRepo.SaveKeepingAdmin(user)
+		if err != nil {
+			return nil, err
+		}
+		if !kept {
+			return nil, ErrLastAdmin
+		}
+	} else if err := userRepo.Save(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
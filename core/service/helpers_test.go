@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+// setupTestDB 在临时目录中创建数据库、主密钥和配置缓存，并写入内置设置的默认值
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	global.DataDir = t.TempDir()
+	if err := global.InitDB(); err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	t.Cleanup(global.CloseDB)
+
+	if err := global.InitMasterKey(); err != nil {
+		t.Fatalf("init master key: %v", err)
+	}
+	if err := global.DB.AutoMigrate(
+		&models.Setting{},
+		&models.TwoFactor{},
+		&models.User{},
+		&models.APIToken{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.LoginThrottle{},
+		&models.AuditLog{},
+		&models.SettingRevision{},
+		&models.LoginEvent{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := NewSettingService().InitializeDefaultSettings(); err != nil {
+		t.Fatalf("default settings: %v", err)
+	}
+	if err := global.InitConfigCache(); err != nil {
+		t.Fatalf("init config cache: %v", err)
+	}
+}
+
+// setTestSettings 直接写入设置并刷新配置缓存，不经过校验
+func setTestSettings(t *testing.T, settings map[string]string) {
+	t.Helper()
+
+	for key, value := range settings {
+		if err := settingRepo.UpdateOrCreate(key, value, ""); err != nil {
+			t.Fatalf("set %s: %v", key, err)
+		}
+	}
+	if err := global.ConfigCacheInstance.Reload(); err != nil {
+		t.Fatalf("reload config cache: %v", err)
+	}
+}
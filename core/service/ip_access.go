@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"gpanel/global"
+	"gpanel/utils"
+)
+
+var ErrIPSelfLockout = errors.New("the IP access lists would block your current IP address")
+
+// IPAccessLists 解析后的 IP 白名单和黑名单
+type IPAccessLists struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+func ParseIPAccessLists(allow, deny string) (*IPAccessLists, error) {
+	allowNets, err := utils.ParseIPList(allow)
+	if err != nil {
+		return nil, fmt.Errorf("IPAllowList: %w", err)
+	}
+	denyNets, err := utils.ParseIPList(deny)
+	if err != nil {
+		return nil, fmt.Errorf("IPDenyList: %w", err)
+	}
+	return &IPAccessLists{Allow: allowNets, Deny: denyNets}, nil
+}
+
+// Allows 黑名单优先；白名单为空时允许所有未被拉黑的地址
+func (l *IPAccessLists) Allows(ip net.IP) bool {
+	if ip == nil {
+		return len(l.Allow) == 0 && len(l.Deny) == 0
+	}
+	if utils.IPListContains(l.Deny, ip) {
+		return false
+	}
+	return len(l.Allow) == 0 || utils.IPListContains(l.Allow, ip)
+}
+
+// ValidateIPAccessUpdate 校验对 IP 列表的修改格式正确，且不会把当前请求者自己拒之门外
+func ValidateIPAccessUpdate(updates map[string]string, clientIP string) error {
+	allow, allowChanged := updates["IPAllowList"]
+	deny, denyChanged := updates["IPDenyList"]
+	if !allowChanged && !denyChanged {
+		return nil
+	}
+
+	if global.ConfigCacheInstance != nil {
+		if !allowChanged {
+			allow = global.ConfigCacheInstance.GetIPAllowList()
+		}
+		if !denyChanged {
+			deny = global.ConfigCacheInstance.GetIPDenyList()
+		}
+	}
+
+	lists, err := ParseIPAccessLists(allow, deny)
+	if err != nil {
+		return err
+	}
+	if !lists.Allows(net.ParseIP(clientIP)) {
+		return ErrIPSelfLockout
+	}
+	return nil
+}
+
+// ValidateAccessUpdate 校验 IP 访问控制和域名绑定的修改不会把当前请求者拒之门外
+func ValidateAccessUpdate(updates map[string]string, clientIP, host string) error {
+	if err := ValidateIPAccessUpdate(updates, clientIP); err != nil {
+		return err
+	}
+	return ValidateDomainBindingUpdate(updates, host)
+}
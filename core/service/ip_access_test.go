@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateAccessUpdate(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		"IPDenyList":    "198.51.100.0/24",
+		"ServerAddress": "panel.example.com",
+	})
+
+	tests := []struct {
+		name     string
+		updates  map[string]string
+		clientIP string
+		host     string
+		wantErr  error
+	}{
+		{"unrelated setting", map[string]string{"LoginMaxAttempts": "3"}, "203.0.113.5", "10.0.0.1", nil},
+		{"allow list contains client", map[string]string{"IPAllowList": "203.0.113.0/24"}, "203.0.113.5", "", nil},
+		{"allow list excludes client", map[string]string{"IPAllowList": "192.0.2.1"}, "203.0.113.5", "", ErrIPSelfLockout},
+		{"deny list contains client", map[string]string{"IPDenyList": "203.0.113.5"}, "203.0.113.5", "", ErrIPSelfLockout},
+		{"stored deny list applies to allow update", map[string]string{"IPAllowList": "198.51.100.0/24"}, "198.51.100.7", "", ErrIPSelfLockout},
+		{"clear lists", map[string]string{"IPAllowList": "", "IPDenyList": ""}, "198.51.100.7", "", nil},
+		{"bind matching host", map[string]string{"BindDomain": "true"}, "203.0.113.5", "panel.example.com:8080", nil},
+		{"bind other host", map[string]string{"BindDomain": "true"}, "203.0.113.5", "10.0.0.1:8080", ErrDomainSelfLockout},
+		{"bind with new address", map[string]string{"BindDomain": "true", "ServerAddress": "*.example.net"}, "203.0.113.5", "a.example.net", nil},
+		{"bind without domains", map[string]string{"BindDomain": "true", "ServerAddress": ""}, "203.0.113.5", "panel.example.com", ErrBindDomainEmpty},
+		{"disable binding", map[string]string{"BindDomain": "false"}, "203.0.113.5", "10.0.0.1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateAccessUpdate(tt.updates, tt.clientIP, tt.host); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := ValidateAccessUpdate(map[string]string{"IPAllowList": "not-an-ip"}, "203.0.113.5", ""); err == nil {
+		t.Fatal("invalid IP list should be rejected")
+	}
+}
+
+func TestValidateDomainBindingUsesStoredState(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{"BindDomain": "true", "ServerAddress": "panel.example.com"})
+
+	// 已开启绑定时只修改域名，也要校验当前访问的域名
+	if err := ValidateDomainBindingUpdate(map[string]string{"ServerAddress": "other.example.com"}, "panel.example.com"); !errors.Is(err, ErrDomainSelfLockout) {
+		t.Fatalf("err = %v, want ErrDomainSelfLockout", err)
+	}
+	if err := ValidateDomainBindingUpdate(map[string]string{"ServerAddress": "panel.example.com, other.example.com"}, "panel.example.com"); err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+var (
+	ErrLDAPNotConfigured = errors.New("ldap is not configured")
+	// ErrLDAPLocalConflict 已存在同名的非 LDAP 账户，不尝试 LDAP 认证
+	ErrLDAPLocalConflict = fmt.Errorf("%w: an account with the same username from another source exists", ErrInvalidCredentials)
+)
+
+// LDAPAuthenticator 通过 LDAP 绑定认证用户，首次登录时自动创建面板账户，并按组映射角色
+type LDAPAuthenticator struct{}
+
+func (a *LDAPAuthenticator) Name() string {
+	return models.UserSourceLDAP
+}
+
+func (a *LDAPAuthenticator) Enabled() bool {
+	return global.ConfigCacheInstance != nil && global.ConfigCacheInstance.GetLDAPConfig().Enabled
+}
+
+func (a *LDAPAuthenticator) Authenticate(username, password string) (*models.User, error) {
+	// 空密码会被很多 LDAP 服务器当作匿名绑定而返回成功
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	// 同名的本地（或 OIDC）账户不会交给 LDAP 认证，避免目录中的同名用户接管该账户，
+	// 需要改用 LDAP 登录时请先删除或重命名本地账户
+	if existing, err := userRepo.GetByUsername(username); err == nil && existing.Source != models.UserSourceLDAP {
+		return nil, ErrLDAPLocalConflict
+	}
+
+	config := global.ConfigCacheInstance.GetLDAPConfig()
+	groups, err := ldapAuthenticate(config, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := mapExternalRole(config.RoleMapping, groups, config.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+	return provisionExternalUser(models.UserSourceLDAP, username, role)
+}
+
+// ldapAuthenticate 使用服务账户搜索用户 DN，再以用户身份绑定校验密码，返回用户所属的组
+func ldapAuthenticate(config global.LDAPConfig, username, password string) ([]string, error) {
+	if config.URL == "" || config.BaseDN == "" {
+		return nil, ErrLDAPNotConfigured
+	}
+
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+
+	conn, err := ldap.DialURL(config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(timeout)
+
+	if config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			return nil, fmt.Errorf("start tls: %w", err)
+		}
+	}
+
+	if config.BindDN != "" {
+		if err := conn.Bind(config.BindDN, config.BindPassword); err != nil {
+			return nil, fmt.Errorf("service account bind: %w", err)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(timeout.Seconds()), false,
+		strings.ReplaceAll(config.UserFilter, "%s", ldap.EscapeFilter(username)),
+		[]string{"dn", config.GroupAttribute},
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("user bind: %w", err)
+	}
+	return entry.GetAttributeValues(config.GroupAttribute), nil
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jimlambrt/gldap"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+// ldapTestEntry 测试目录中的用户条目
+type ldapTestEntry struct {
+	dn       string
+	password string
+	groups   []string
+}
+
+// startTestLDAP 在本地启动 LDAP 服务器，服务账户为 cn=svc,dc=example,dc=com / svcpw
+func startTestLDAP(t *testing.T, users map[string]ldapTestEntry) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	server, err := gldap.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+		defer func() { _ = w.Write(resp) }()
+
+		m, err := r.GetSimpleBindMessage()
+		if err != nil {
+			return
+		}
+		if m.UserName == "cn=svc,dc=example,dc=com" && string(m.Password) == "svcpw" {
+			resp.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+		for _, entry := range users {
+			if entry.dn == m.UserName && entry.password == string(m.Password) {
+				resp.SetResultCode(gldap.ResultSuccess)
+				return
+			}
+		}
+	})
+	_ = mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+		defer func() { _ = w.Write(resp) }()
+
+		m, err := r.GetSearchMessage()
+		if err != nil {
+			resp.SetResultCode(gldap.ResultOperationsError)
+			return
+		}
+		for uid, entry := range users {
+			if m.Filter == fmt.Sprintf("(uid=%s)", uid) {
+				_ = w.Write(r.NewSearchResponseEntry(entry.dn, gldap.WithAttributes(map[string][]string{
+					"memberOf": entry.groups,
+				})))
+			}
+		}
+	})
+	if err := server.Router(mux); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = server.Run(addr) }()
+	t.Cleanup(func() { _ = server.Stop() })
+	for i := 0; i < 100 && !server.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return "ldap://" + addr
+}
+
+func setupTestLDAP(t *testing.T) {
+	t.Helper()
+
+	setupTestDB(t)
+	url := startTestLDAP(t, map[string]ldapTestEntry{
+		"alice": {dn: "uid=alice,ou=people,dc=example,dc=com", password: "alicepw", groups: []string{"cn=ops,ou=groups,dc=example,dc=com"}},
+		"carol": {dn: "uid=carol,ou=people,dc=example,dc=com", password: "carolpw", groups: []string{
+			"cn=ops,ou=groups,dc=example,dc=com",
+			"CN=Admins,ou=groups,dc=example,dc=com",
+		}},
+		"dave": {dn: "uid=dave,ou=people,dc=example,dc=com", password: "davepw"},
+		"root": {dn: "uid=root,ou=people,dc=example,dc=com", password: "rootpw"},
+	})
+	setTestSettings(t, map[string]string{
+		"LDAPEnabled":     "true",
+		"LDAPURL":         url,
+		"LDAPBindDN":      "cn=svc,dc=example,dc=com",
+		"LDAPBaseDN":      "dc=example,dc=com",
+		"LDAPRoleMapping": `{"cn=ops,ou=groups,dc=example,dc=com":"operator","cn=admins,ou=groups,dc=example,dc=com":"admin"}`,
+	})
+	// 绑定密码属于敏感设置，需加密写入
+	encrypted, err := global.EncryptSecret("svcpw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := settingRepo.MarkSecret("LDAPBindPassword", encrypted); err != nil {
+		t.Fatal(err)
+	}
+	setTestSettings(t, nil)
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	setupTestLDAP(t)
+
+	local, err := NewUserService().Create("root", "Local-Pass-123", models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantRole string
+		wantErr  bool
+	}{
+		{"group mapped to role", "alice", "alicepw", models.RoleOperator, false},
+		{"highest role wins", "carol", "carolpw", models.RoleAdmin, false},
+		{"wrong password", "alice", "wrong", "", true},
+		{"empty password", "alice", "", "", true},
+		{"missing user", "mallory", "whatever", "", true},
+		{"no mapped group", "dave", "davepw", "", true},
+		{"local account is not taken over", "root", "rootpw", "", true},
+		{"local account falls back to local password", "root", "Local-Pass-123", models.RoleAdmin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := AuthenticateLogin(tt.username, tt.password)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticate: %v", err)
+			}
+			if user.Username != tt.username || user.Role != tt.wantRole {
+				t.Fatalf("got %s/%s, want %s/%s", user.Username, user.Role, tt.username, tt.wantRole)
+			}
+		})
+	}
+
+	alice, err := userRepo.GetByUsername("alice")
+	if err != nil || alice.Source != models.UserSourceLDAP {
+		t.Fatalf("alice was not provisioned as an LDAP user: %+v, %v", alice, err)
+	}
+	if local.Source != models.UserSourceLocal {
+		t.Fatalf("root source = %q, want local", local.Source)
+	}
+	if _, err := (&LDAPAuthenticator{}).Authenticate("root", "rootpw"); !errors.Is(err, ErrLDAPLocalConflict) {
+		t.Fatalf("err = %v, want ErrLDAPLocalConflict", err)
+	}
+}
+
+func TestLDAPUnavailableFallsBackToLocal(t *testing.T) {
+	setupTestDB(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "ldap://" + listener.Addr().String()
+	listener.Close()
+	setTestSettings(t, map[string]string{
+		"LDAPEnabled":     "true",
+		"LDAPURL":         closedURL,
+		"LDAPBaseDN":      "dc=example,dc=com",
+		"LDAPDefaultRole": models.RoleReadOnly,
+		"LDAPTimeout":     "1",
+	})
+
+	if _, err := NewUserService().Create("ops", "Local-Pass-123", models.RoleOperator); err != nil {
+		t.Fatal(err)
+	}
+	user, err := AuthenticateLogin("ops", "Local-Pass-123")
+	if err != nil || user.Source != models.UserSourceLocal {
+		t.Fatalf("local fallback failed: %+v, %v", user, err)
+	}
+	if _, err := AuthenticateLogin("nobody", "whatever"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/repo"
+	"gpanel/utils"
+)
+
+// loginEventCleanupInterval 清理过期登录记录的最小间隔
+const loginEventCleanupInterval = time.Hour
+
+// 登录通知的事件类型
+const (
+	NotifyEventLogin            = "login"
+	NotifyEventLoginNewLocation = "login.new_location"
+	NotifyEventLoginNewDevice   = "login.new_device"
+)
+
+type LoginEventService struct{}
+
+type ILoginEventService interface {
+	RecordSuccess(user *models.User, ip, userAgent, method string)
+	RecordFailure(username, ip, userAgent, method, reason string)
+	List(filter repo.LoginEventFilter, page, pageSize int) ([]models.LoginEvent, int64, error)
+}
+
+func NewLoginEventService() ILoginEventService {
+	return &LoginEventService{}
+}
+
+var loginEventRepo repo.ILoginEventRepo = repo.NewLoginEventRepo()
+
+var (
+	loginEventCleanupMu   sync.Mutex
+	lastLoginEventCleanup time.Time
+)
+
+// RecordSuccess 记录成功登录。用户此前有登录记录时，网段从未出现过视为新位置，User-Agent 从未出现过视为新设备。
+// 位置只比较网段（IPv4 /24、IPv6 /48），不查询 ASN，同一运营商换了网段也会被视为新位置
+func (s *LoginEventService) RecordSuccess(user *models.User, ip, userAgent, method string) {
+	event := &models.LoginEvent{
+		UserID:    user.ID,
+		Username:  user.Username,
+		IP:        ip,
+		Network:   utils.IPNetwork(ip),
+		UserAgent: truncate(userAgent, 512),
+		Method:    method,
+		Success:   true,
+	}
+
+	// 首次登录没有可比较的历史，不视为新位置
+	if count, err := loginEventRepo.CountSuccess(user.ID); err == nil && count > 0 {
+		seenNetwork, err := loginEventRepo.HasSuccessFromNetwork(user.ID, event.Network)
+		event.NewNetwork = err == nil && !seenNetwork
+		seenUserAgent, err := loginEventRepo.HasSuccessWithUserAgent(user.ID, event.UserAgent)
+		event.NewUserAgent = err == nil && !seenUserAgent
+	}
+
+	s.save(event)
+
+	mode := "new"
+	if global.ConfigCacheInstance != nil {
+		mode = global.ConfigCacheInstance.GetLoginNotify()
+	}
+	if mode == "all" || (mode == "new" && (event.NewNetwork || event.NewUserAgent)) {
+		Notify(loginNotification(event))
+	}
+}
+
+// RecordFailure 记录失败登录，用户名对应的用户存在时关联到该用户
+func (s *LoginEventService) RecordFailure(username, ip, userAgent, method, reason string) {
+	event := &models.LoginEvent{
+		Username:  truncate(username, 256),
+		IP:        ip,
+		Network:   utils.IPNetwork(ip),
+		UserAgent: truncate(userAgent, 512),
+		Method:    method,
+		Reason:    truncate(reason, 256),
+	}
+	if user, err := userRepo.GetByUsername(username); err == nil {
+		event.UserID = user.ID
+	}
+	s.save(event)
+}
+
+func (s *LoginEventService) List(filter repo.LoginEventFilter, page, pageSize int) ([]models.LoginEvent, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	return loginEventRepo.List(filter, (page-1)*pageSize, pageSize)
+}
+
+// save 写入登录记录，并按保留天数定期清理过期记录
+func (s *LoginEventService) save(event *models.LoginEvent) {
+	if err := loginEventRepo.Create(event); err != nil {
+		log.Printf("Failed to record login event for %s: %v", event.Username, err)
+	}
+
+	loginEventCleanupMu.Lock()
+	defer loginEventCleanupMu.Unlock()
+
+	now := time.Now()
+	if now.Sub(lastLoginEventCleanup) < loginEventCleanupInterval {
+		return
+	}
+	lastLoginEventCleanup = now
+
+	retentionDays := 90
+	if global.ConfigCacheInstance != nil {
+		retentionDays = global.ConfigCacheInstance.GetLoginHistoryRetentionDays()
+	}
+	if retentionDays > 0 {
+		if err := loginEventRepo.DeleteBefore(now.AddDate(0, 0, -retentionDays)); err != nil {
+			log.Printf("Failed to clean up login events: %v", err)
+		}
+	}
+}
+
+func loginNotification(event *models.LoginEvent) Notification {
+	n := Notification{
+		Event: NotifyEventLogin,
+		Title: fmt.Sprintf("GPanel login: %s", event.Username),
+		Data:  event,
+		Time:  event.CreatedAt,
+	}
+
+	var reasons []string
+	if event.NewNetwork {
+		reasons = append(reasons, "new network")
+	}
+	if event.NewUserAgent {
+		reasons = append(reasons, "new device")
+	}
+	switch {
+	case event.NewNetwork:
+		n.Event = NotifyEventLoginNewLocation
+		n.Title = fmt.Sprintf("GPanel login from a new location: %s", event.Username)
+	case event.NewUserAgent:
+		n.Event = NotifyEventLoginNewDevice
+		n.Title = fmt.Sprintf("GPanel login from a new device: %s", event.Username)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "User: %s\n", event.Username)
+	fmt.Fprintf(&msg, "Time: %s\n", event.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&msg, "IP: %s (%s)\n", event.IP, event.Network)
+	fmt.Fprintf(&msg, "User-Agent: %s\n", event.UserAgent)
+	fmt.Fprintf(&msg, "Method: %s\n", event.Method)
+	if len(reasons) > 0 {
+		fmt.Fprintf(&msg, "Detected: %s\n", strings.Join(reasons, ", "))
+	}
+	msg.WriteString("\nIf this was not you, revoke the session and change the password immediately.\n")
+	n.Message = msg.String()
+	return n
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
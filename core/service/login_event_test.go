@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+
+	"gpanel/models"
+	"gpanel/repo"
+)
+
+func TestLoginEventNewLocation(t *testing.T) {
+	setupTestDB(t)
+	user := &models.User{Username: "alice", Role: models.RoleAdmin}
+	if err := userRepo.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	events := NewLoginEventService()
+	events.RecordSuccess(user, "203.0.113.10", "firefox", models.LoginMethodPassword)
+
+	tests := []struct {
+		name        string
+		ip          string
+		userAgent   string
+		wantNetwork bool
+		wantUA      bool
+		wantEvent   string
+	}{
+		{"same /24", "203.0.113.99", "firefox", false, false, NotifyEventLogin},
+		{"new /24", "198.51.100.7", "firefox", true, false, NotifyEventLoginNewLocation},
+		{"new user agent", "203.0.113.20", "curl", false, true, NotifyEventLoginNewDevice},
+		{"first IPv6 /48", "2001:db8:1:2::1", "firefox", true, false, NotifyEventLoginNewLocation},
+		{"other host in /48", "2001:db8:1:ffff::2", "firefox", false, false, NotifyEventLogin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events.RecordSuccess(user, tt.ip, tt.userAgent, models.LoginMethodPassword)
+			list, _, err := events.List(repo.LoginEventFilter{UserID: user.ID}, 1, 1)
+			if err != nil || len(list) != 1 {
+				t.Fatalf("list: %v", err)
+			}
+			event := list[0]
+			if event.NewNetwork != tt.wantNetwork || event.NewUserAgent != tt.wantUA {
+				t.Fatalf("new network/ua = %v/%v, want %v/%v", event.NewNetwork, event.NewUserAgent, tt.wantNetwork, tt.wantUA)
+			}
+			if got := loginNotification(&event).Event; got != tt.wantEvent {
+				t.Fatalf("event = %q, want %q", got, tt.wantEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/repo"
+)
+
+// maxLockoutDuration 指数增长后的最长锁定时间
+const maxLockoutDuration = 24 * time.Hour
+
+type LoginThrottleService struct{}
+
+type ILoginThrottleService interface {
+	Reserve(ip, username string) (time.Duration, bool)
+	RecordFailure(ip, username string)
+	RecordSuccess(ip, username string)
+	Release(ip, username string)
+	List() ([]models.LoginThrottle, error)
+	Clear(id uint) error
+	ClearAll() error
+}
+
+func NewLoginThrottleService() ILoginThrottleService {
+	return &LoginThrottleService{}
+}
+
+var loginThrottleRepo repo.ILoginThrottleRepo = repo.NewLoginThrottleRepo()
+
+// loginThrottleMu 串行化失败计数的读写
+var loginThrottleMu sync.Mutex
+
+// pendingAttempts 已通过 Reserve 但尚未得出结果的尝试次数，由 loginThrottleMu 保护
+var pendingAttempts = map[throttleTarget]int{}
+
+type throttlePolicy struct {
+	maxAttempts int
+	lockout     time.Duration
+	backoffBase time.Duration
+	window      time.Duration
+}
+
+func currentThrottlePolicy() throttlePolicy {
+	policy := throttlePolicy{
+		maxAttempts: 5,
+		lockout:     900 * time.Second,
+		backoffBase: time.Second,
+		window:      time.Hour,
+	}
+	if global.ConfigCacheInstance != nil {
+		policy.maxAttempts = global.ConfigCacheInstance.GetLoginMaxAttempts()
+		policy.lockout = time.Duration(global.ConfigCacheInstance.GetLoginLockoutDuration()) * time.Second
+		policy.backoffBase = time.Duration(global.ConfigCacheInstance.GetLoginBackoffBase()) * time.Second
+		policy.window = time.Duration(global.ConfigCacheInstance.GetLoginAttemptWindow()) * time.Second
+	}
+	if policy.maxAttempts < 1 {
+		policy.maxAttempts = 1
+	}
+	return policy
+}
+
+// blockDuration 计算第 failures 次失败后需要等待的时间：
+// 未达到阈值时按 backoffBase 指数退避，达到阈值后按 lockout 指数增长
+func (p throttlePolicy) blockDuration(failures int) time.Duration {
+	var d time.Duration
+	if failures < p.maxAttempts {
+		d = p.backoffBase << uint(min(failures-1, 20))
+		if d > p.lockout {
+			d = p.lockout
+		}
+	} else {
+		d = p.lockout << uint(min(failures-p.maxAttempts, 20))
+	}
+	if d > maxLockoutDuration || d < 0 {
+		d = maxLockoutDuration
+	}
+	return d
+}
+
+// Reserve 判断 IP 或用户名是否处于锁定中，未锁定时预占一次尝试并返回 false；
+// 已记录的失败次数加上进行中的尝试达到上限时同样拒绝，避免并发请求绕过计数。
+// 预占成功后必须调用 RecordFailure、RecordSuccess 或 Release 之一结束本次尝试。
+func (s *LoginThrottleService) Reserve(ip, username string) (time.Duration, bool) {
+	loginThrottleMu.Lock()
+	defer loginThrottleMu.Unlock()
+
+	policy := currentThrottlePolicy()
+	now := time.Now()
+	targets := throttleTargets(ip, username)
+	var retryAfter time.Duration
+	for _, target := range targets {
+		failures := 0
+		if throttle, err := loginThrottleRepo.Get(target.kind, target.key); err == nil {
+			if throttle.BlockedUntil != nil {
+				if remaining := throttle.BlockedUntil.Sub(now); remaining > retryAfter {
+					retryAfter = remaining
+				}
+			}
+			if policy.window <= 0 || now.Sub(throttle.LastFailureAt) <= policy.window {
+				failures = throttle.Failures
+			}
+		}
+		// 进行中的尝试全部失败即会触发锁定，先让后来者等待结果
+		if pending := pendingAttempts[target]; pending > 0 && failures+pending >= policy.maxAttempts {
+			if wait := policy.backoffBase; wait > retryAfter {
+				retryAfter = wait
+			}
+			if retryAfter <= 0 {
+				retryAfter = time.Second
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return retryAfter, true
+	}
+
+	for _, target := range targets {
+		pendingAttempts[target]++
+	}
+	return 0, false
+}
+
+// RecordFailure 将预占的尝试记为一次失败并更新锁定时间
+func (s *LoginThrottleService) RecordFailure(ip, username string) {
+	loginThrottleMu.Lock()
+	defer loginThrottleMu.Unlock()
+
+	policy := currentThrottlePolicy()
+	now := time.Now()
+	for _, target := range throttleTargets(ip, username) {
+		releasePending(target)
+		throttle, err := loginThrottleRepo.Get(target.kind, target.key)
+		if err != nil {
+			throttle = &models.LoginThrottle{Kind: target.kind, Key: target.key}
+		}
+
+		// 超过统计窗口没有失败，重新计数
+		if policy.window > 0 && now.Sub(throttle.LastFailureAt) > policy.window {
+			throttle.Failures = 0
+		}
+
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		blockedUntil := now.Add(policy.blockDuration(throttle.Failures))
+		throttle.BlockedUntil = &blockedUntil
+
+		if err := loginThrottleRepo.Save(throttle); err != nil {
+			log.Printf("Failed to record login failure for %s %s: %v", target.kind, target.key, err)
+		}
+		if throttle.Failures == policy.maxAttempts {
+			log.Printf("Login locked for %s %s until %s", target.kind, target.key, blockedUntil.Format(time.RFC3339))
+		}
+	}
+}
+
+// RecordSuccess 登录成功后清除失败记录
+func (s *LoginThrottleService) RecordSuccess(ip, username string) {
+	loginThrottleMu.Lock()
+	defer loginThrottleMu.Unlock()
+
+	for _, target := range throttleTargets(ip, username) {
+		releasePending(target)
+		if err := loginThrottleRepo.Delete(target.kind, target.key); err != nil {
+			log.Printf("Failed to clear login failures for %s %s: %v", target.kind, target.key, err)
+		}
+	}
+}
+
+// Release 结束预占的尝试但不计入结果，例如密码正确但仍需双因素验证
+func (s *LoginThrottleService) Release(ip, username string) {
+	loginThrottleMu.Lock()
+	defer loginThrottleMu.Unlock()
+
+	for _, target := range throttleTargets(ip, username) {
+		releasePending(target)
+	}
+}
+
+func releasePending(target throttleTarget) {
+	if pendingAttempts[target] <= 1 {
+		delete(pendingAttempts, target)
+		return
+	}
+	pendingAttempts[target]--
+}
+
+func (s *LoginThrottleService) List() ([]models.LoginThrottle, error) {
+	return loginThrottleRepo.List()
+}
+
+func (s *LoginThrottleService) Clear(id uint) error {
+	return loginThrottleRepo.DeleteByID(id)
+}
+
+func (s *LoginThrottleService) ClearAll() error {
+	return loginThrottleRepo.DeleteAll()
+}
+
+type throttleTarget struct {
+	kind string
+	key  string
+}
+
+// throttleTargets 返回需要计数的维度。用户名维度只针对已存在的用户，
+// 否则任意不存在的用户名都会新增一行记录，这类猜测由 IP 维度限制
+func throttleTargets(ip, username string) []throttleTarget {
+	targets := make([]throttleTarget, 0, 2)
+	if ip != "" {
+		targets = append(targets, throttleTarget{models.ThrottleKindIP, ip})
+	}
+	if username != "" {
+		if _, err := userRepo.GetByUsername(username); err == nil {
+			targets = append(targets, throttleTarget{models.ThrottleKindUser, username})
+		}
+	}
+	return targets
+}
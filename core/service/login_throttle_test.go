@@ -0,0 +1,120 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gpanel/models"
+)
+
+func TestThrottleBlockDuration(t *testing.T) {
+	policy := throttlePolicy{maxAttempts: 3, lockout: time.Minute, backoffBase: time.Second, window: time.Hour}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{40, maxLockoutDuration},
+	}
+	for _, tt := range tests {
+		if got := policy.blockDuration(tt.failures); got != tt.want {
+			t.Errorf("blockDuration(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottleParallelReservations(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{"LoginMaxAttempts": "3", "LoginBackoffBase": "0"})
+	if err := userRepo.Create(&models.User{Username: "alice", Role: models.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	throttle := NewLoginThrottleService()
+
+	// 并发请求在任何失败写入前到达，只有 maxAttempts 个能通过
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, blocked := throttle.Reserve("10.0.0.1", "alice"); !blocked {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 3 {
+		t.Fatalf("allowed %d parallel attempts, want 3", allowed)
+	}
+
+	for i := 0; i < allowed; i++ {
+		throttle.RecordFailure("10.0.0.1", "alice")
+	}
+	if _, blocked := throttle.Reserve("10.0.0.2", "alice"); !blocked {
+		t.Fatal("user should be locked after maxAttempts failures")
+	}
+	if _, blocked := throttle.Reserve("10.0.0.1", ""); !blocked {
+		t.Fatal("ip should be locked after maxAttempts failures")
+	}
+}
+
+func TestLoginThrottleReleaseAndSuccess(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{"LoginMaxAttempts": "1", "LoginBackoffBase": "0"})
+	if err := userRepo.Create(&models.User{Username: "alice", Role: models.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	throttle := NewLoginThrottleService()
+
+	tests := []struct {
+		name   string
+		finish func(ip, username string)
+	}{
+		{"release", throttle.Release},
+		{"success", throttle.RecordSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, blocked := throttle.Reserve("10.0.0.1", "alice"); blocked {
+				t.Fatal("first attempt should pass")
+			}
+			if _, blocked := throttle.Reserve("10.0.0.1", "alice"); !blocked {
+				t.Fatal("second in-flight attempt should wait")
+			}
+			tt.finish("10.0.0.1", "alice")
+			if _, blocked := throttle.Reserve("10.0.0.1", "alice"); blocked {
+				t.Fatal("attempt should pass once the reservation ends")
+			}
+			throttle.Release("10.0.0.1", "alice")
+		})
+	}
+}
+
+func TestLoginThrottleUnknownUsername(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{"LoginMaxAttempts": "10", "LoginBackoffBase": "0"})
+	throttle := NewLoginThrottleService()
+
+	for _, username := range []string{"ghost1", "ghost2", "ghost3"} {
+		if _, blocked := throttle.Reserve("10.0.0.1", username); blocked {
+			t.Fatalf("%s: unexpectedly blocked", username)
+		}
+		throttle.RecordFailure("10.0.0.1", username)
+	}
+
+	throttles, err := throttle.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(throttles) != 1 || throttles[0].Kind != models.ThrottleKindIP {
+		t.Fatalf("got %d throttle rows, want only the ip row", len(throttles))
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"gpanel/global"
+)
+
+// notifyTimeout Webhook 和 SMTP 的超时时间
+const notifyTimeout = 10 * time.Second
+
+var notifyHTTPClient = &http.Client{Timeout: notifyTimeout}
+
+// Notification 通过 Webhook 和邮件发送的事件通知
+type Notification struct {
+	Event   string    `json:"event"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Data    any       `json:"data,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Notify 异步发送到已配置的 Webhook 和邮箱，发送失败只记录日志
+func Notify(n Notification) {
+	if global.ConfigCacheInstance == nil {
+		return
+	}
+	webhookURL := global.ConfigCacheInstance.GetNotifyWebhookURL()
+	recipients := splitList(global.ConfigCacheInstance.GetNotifyEmail())
+	smtpConfig := global.ConfigCacheInstance.GetSMTPConfig()
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+
+	go func() {
+		if webhookURL != "" {
+			if err := sendWebhook(webhookURL, n); err != nil {
+				log.Printf("Failed to send %s notification to webhook: %v", n.Event, err)
+			}
+		}
+		if len(recipients) > 0 && smtpConfig.Host != "" {
+			if err := sendEmail(smtpConfig, recipients, n.Title, n.Message); err != nil {
+				log.Printf("Failed to send %s notification by email: %v", n.Event, err)
+			}
+		}
+	}()
+}
+
+func sendWebhook(url string, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	resp, err := notifyHTTPClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendEmail 发送纯文本邮件。465 端口直接使用 TLS，其他端口在服务器支持时升级为 STARTTLS
+func sendEmail(config global.SMTPConfig, to []string, subject, body string) error {
+	if config.From == "" {
+		return errors.New("smtp sender address is not configured")
+	}
+
+	addr := net.JoinHostPort(config.Host, config.Port)
+	tlsConfig := &tls.Config{ServerName: config.Host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: notifyTimeout}
+	if config.Port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(notifyTimeout))
+
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && config.Port != "465" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(config.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	var msg strings.Builder
+	msg.WriteString("From: " + config.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// splitList 拆分逗号、分号或空白分隔的列表
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
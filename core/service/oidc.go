@@ -0,0 +1,461 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+const (
+	// oidcStateTTL 发起登录到回调之间允许的最长时间
+	oidcStateTTL = 10 * time.Minute
+	// oidcProviderTTL 发现文档和 JWKS 的缓存时间
+	oidcProviderTTL = time.Hour
+	// OIDCStateCookieName 发起登录的浏览器中保存 state 哈希的 cookie，回调时校验，防止登录 CSRF
+	OIDCStateCookieName = "gpanel_oidc_state"
+	// OIDCStateCookieMaxAge state cookie 的有效期（秒），与 state 有效期一致
+	OIDCStateCookieMaxAge = int(oidcStateTTL / time.Second)
+)
+
+var (
+	ErrOIDCDisabled = errors.New("oidc login is not enabled")
+	// ErrOIDCRedirectURL 回调地址必须在设置中固定，不能由请求的 Host 头决定
+	ErrOIDCRedirectURL = errors.New("OIDCRedirectURL is not configured")
+	ErrOIDCState       = errors.New("oidc login state is invalid or expired")
+	ErrOIDCIDToken     = errors.New("oidc id token is invalid")
+)
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// oidcSigningMethods ID Token 允许的签名算法
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	issuer    string
+	discovery oidcDiscovery
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// oidcLoginState 一次登录流程的 state，保存 PKCE code_verifier 和 nonce，回调时只能使用一次
+type oidcLoginState struct {
+	nonce        string
+	codeVerifier string
+	expiresAt    time.Time
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcCached   *oidcProvider
+	oidcStates   = make(map[string]oidcLoginState)
+	oidcStatesMu sync.Mutex
+)
+
+type OIDCService struct{}
+
+type IOIDCService interface {
+	Config() global.OIDCConfig
+	AuthorizationURL(ctx context.Context) (authURL, state string, err error)
+	HandleCallback(ctx context.Context, code, state, stateCookie string) (*models.User, error)
+}
+
+func NewOIDCService() IOIDCService {
+	return &OIDCService{}
+}
+
+// Config 每次从配置缓存读取，修改设置后立即生效
+func (s *OIDCService) Config() global.OIDCConfig {
+	if global.ConfigCacheInstance == nil {
+		return global.OIDCConfig{}
+	}
+	return global.ConfigCacheInstance.GetOIDCConfig()
+}
+
+// OIDCStateCookieValue state cookie 中保存的值，为 state 的 SHA-256 哈希
+func OIDCStateCookieValue(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthorizationURL 生成跳转到身份提供商的授权地址（授权码模式 + PKCE），
+// 返回的 state 需由调用方通过 OIDCStateCookieValue 写入发起登录的浏览器
+func (s *OIDCService) AuthorizationURL(ctx context.Context) (string, string, error) {
+	config := s.Config()
+	if !config.Enabled || config.Issuer == "" || config.ClientID == "" {
+		return "", "", ErrOIDCDisabled
+	}
+	if config.RedirectURL == "" {
+		return "", "", ErrOIDCRedirectURL
+	}
+
+	provider, err := loadOIDCProvider(ctx, config.Issuer, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomURLString(24)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomURLString(24)
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := randomURLString(48)
+	if err != nil {
+		return "", "", err
+	}
+
+	oidcStatesMu.Lock()
+	now := time.Now()
+	for k, v := range oidcStates {
+		if now.After(v.expiresAt) {
+			delete(oidcStates, k)
+		}
+	}
+	oidcStates[state] = oidcLoginState{
+		nonce:        nonce,
+		codeVerifier: codeVerifier,
+		expiresAt:    now.Add(oidcStateTTL),
+	}
+	oidcStatesMu.Unlock()
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {config.ClientID},
+		"redirect_uri":          {config.RedirectURL},
+		"scope":                 {config.Scopes},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	endpoint := provider.discovery.AuthorizationEndpoint
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + query.Encode(), state, nil
+	}
+	return endpoint + "?" + query.Encode(), state, nil
+}
+
+// HandleCallback 校验 state 及其与发起登录的浏览器的绑定，用授权码换取 ID Token 并校验，返回 iss 和 sub 对应的面板用户
+func (s *OIDCService) HandleCallback(ctx context.Context, code, state, stateCookie string) (*models.User, error) {
+	config := s.Config()
+	if !config.Enabled || config.Issuer == "" || config.ClientID == "" {
+		return nil, ErrOIDCDisabled
+	}
+	if config.RedirectURL == "" {
+		return nil, ErrOIDCRedirectURL
+	}
+	// state 必须来自当前浏览器发起的登录，否则攻击者可诱导受害者登录到攻击者的账户
+	if state == "" || subtle.ConstantTimeCompare([]byte(OIDCStateCookieValue(state)), []byte(stateCookie)) != 1 {
+		return nil, ErrOIDCState
+	}
+
+	oidcStatesMu.Lock()
+	loginState, ok := oidcStates[state]
+	delete(oidcStates, state)
+	oidcStatesMu.Unlock()
+	if !ok || time.Now().After(loginState.expiresAt) {
+		return nil, ErrOIDCState
+	}
+
+	provider, err := loadOIDCProvider(ctx, config.Issuer, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := exchangeOIDCCode(ctx, provider, config, code, loginState)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := verifyOIDCIDToken(ctx, config, rawIDToken, loginState.nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing claim sub", ErrOIDCIDToken)
+	}
+	username, _ := claims[config.UsernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("%w: missing claim %s", ErrOIDCIDToken, config.UsernameClaim)
+	}
+
+	role, err := mapExternalRole(config.RoleMapping, claimValues(claims[config.RoleClaim]), config.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+	return provisionOIDCUser(config.Issuer, subject, username, role)
+}
+
+// loadOIDCProvider 获取发现文档和 JWKS，按 issuer 缓存，refresh 为 true 时强制刷新（签名密钥轮换）
+func loadOIDCProvider(ctx context.Context, issuer string, refresh bool) (*oidcProvider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if !refresh && oidcCached != nil && oidcCached.issuer == issuer && time.Since(oidcCached.fetchedAt) < oidcProviderTTL {
+		return oidcCached, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if discovery.Issuer != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+
+	keys, err := fetchJWKS(ctx, discovery.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	oidcCached = &oidcProvider{
+		issuer:    issuer,
+		discovery: discovery,
+		keys:      keys,
+		fetchedAt: time.Now(),
+	}
+	return oidcCached, nil
+}
+
+func exchangeOIDCCode(ctx context.Context, provider *oidcProvider, config global.OIDCConfig, code string, state oidcLoginState) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {config.RedirectURL},
+		"client_id":     {config.ClientID},
+		"code_verifier": {state.codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("oidc token exchange: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.IDToken == "" {
+		return "", fmt.Errorf("oidc token exchange: %s %s", result.Error, result.ErrorDescription)
+	}
+	return result.IDToken, nil
+}
+
+// verifyOIDCIDToken 校验签名、issuer、audience、过期时间和 nonce
+func verifyOIDCIDToken(ctx context.Context, config global.OIDCConfig, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		provider, err := loadOIDCProvider(ctx, config.Issuer, false)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := provider.lookupKey(kid)
+		if !ok {
+			// 身份提供商可能已轮换签名密钥
+			if provider, err = loadOIDCProvider(ctx, config.Issuer, true); err != nil {
+				return nil, err
+			}
+			if key, ok = provider.lookupKey(kid); !ok {
+				return nil, fmt.Errorf("unknown signing key %q", kid)
+			}
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithAudience(config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCIDToken, err)
+	}
+
+	if claims["nonce"] != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCIDToken)
+	}
+	// 存在多个 audience 时 azp 必须是本客户端
+	if audience, _ := claims.GetAudience(); len(audience) > 1 && claims["azp"] != config.ClientID {
+		return nil, fmt.Errorf("%w: azp mismatch", ErrOIDCIDToken)
+	}
+	return claims, nil
+}
+
+// lookupKey 按 kid 查找公钥，ID Token 未携带 kid 且只有一个密钥时使用该密钥
+func (p *oidcProvider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func fetchJWKS(ctx context.Context, jwksURI string) (map[string]any, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]any)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// claimValues 声明值可能是字符串或字符串数组
+func claimValues(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func randomURLString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"gpanel/models"
+)
+
+const testOIDCRedirectURL = "https://panel.test/api/v1/auth/oidc/callback"
+
+// mockIssuer 本地 OIDC 身份提供商，提供发现文档、JWKS 和令牌接口
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+	// issuer 发现文档中返回的 issuer，为空时使用服务器地址
+	issuer string
+	// claims 修改签发的 ID Token 声明，用于构造各种非法令牌
+	claims func(claims jwt.MapClaims)
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key, kid: "k1", codes: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.issuer
+		if issuer == "" {
+			issuer = m.server.URL
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.handleToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize 模拟用户在身份提供商处完成登录，返回授权码
+func (m *mockIssuer) authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("redirect_uri") != testOIDCRedirectURL {
+		t.Fatalf("redirect_uri = %q", query.Get("redirect_uri"))
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q", query.Get("code_challenge_method"))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code = "code-" + query.Get("state")
+	m.codes[code] = query
+	return code, query.Get("state")
+}
+
+func (m *mockIssuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	clientID, secret, _ := r.BasicAuth()
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || clientID != "gpanel" || secret != "s3cret" ||
+		r.PostForm.Get("redirect_uri") != auth.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != auth.Get("code_challenge") {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                m.server.URL,
+		"aud":                "gpanel",
+		"sub":                "u1",
+		"preferred_username": "bob",
+		"groups":             []string{"ops"},
+		"nonce":              auth.Get("nonce"),
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Minute).Unix(),
+	}
+	if m.claims != nil {
+		m.claims(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = m.kid
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "access_token": "x", "token_type": "Bearer"})
+}
+
+func setupOIDC(t *testing.T) *mockIssuer {
+	t.Helper()
+	setupTestDB(t)
+	issuer := newMockIssuer(t)
+	setTestSettings(t, map[string]string{
+		"OIDCEnabled":      "true",
+		"OIDCIssuer":       issuer.server.URL,
+		"OIDCClientID":     "gpanel",
+		"OIDCClientSecret": "s3cret",
+		"OIDCRedirectURL":  testOIDCRedirectURL,
+		"OIDCRoleClaim":    "groups",
+		"OIDCRoleMapping":  `{"ops":"operator"}`,
+	})
+	return issuer
+}
+
+func TestOIDCLogin(t *testing.T) {
+	issuer := setupOIDC(t)
+	svc := NewOIDCService()
+	ctx := context.Background()
+
+	authURL, state, err := svc.AuthorizationURL(ctx)
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	code, returnedState := issuer.authorize(t, authURL)
+	if returnedState != state {
+		t.Fatalf("state = %q, want %q", returnedState, state)
+	}
+
+	user, err := svc.HandleCallback(ctx, code, state, OIDCStateCookieValue(state))
+	if err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	if user.Username != "bob" || user.Role != models.RoleOperator || user.Source != models.UserSourceOIDC {
+		t.Fatalf("user = %s/%s/%s", user.Username, user.Role, user.Source)
+	}
+
+	// state 只能使用一次
+	if _, err := svc.HandleCallback(ctx, code, state, OIDCStateCookieValue(state)); !errors.Is(err, ErrOIDCState) {
+		t.Fatalf("replayed state: err = %v, want ErrOIDCState", err)
+	}
+}
+
+// oidcLogin 以指定的 sub 和用户名声明完成一次单点登录
+func oidcLogin(t *testing.T, issuer *mockIssuer, subject, username string) (*models.User, error) {
+	t.Helper()
+
+	issuer.claims = func(c jwt.MapClaims) {
+		c["sub"] = subject
+		c["preferred_username"] = username
+	}
+	svc := NewOIDCService()
+	authURL, state, err := svc.AuthorizationURL(context.Background())
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	code, _ := issuer.authorize(t, authURL)
+	return svc.HandleCallback(context.Background(), code, state, OIDCStateCookieValue(state))
+}
+
+func TestOIDCUserMatchedBySubject(t *testing.T) {
+	issuer := setupOIDC(t)
+	if err := userRepo.Create(&models.User{Username: "alice", Role: models.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := oidcLogin(t, issuer, "u1", "bob")
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		subject  string
+		username string
+		wantID   uint
+		wantErr  error
+	}{
+		{"same subject", "u1", "bob", first.ID, nil},
+		{"same subject with a new username", "u1", "robert", first.ID, nil},
+		{"other subject claiming the username", "u2", "bob", 0, ErrInvalidCredentials},
+		{"other subject claiming a local username", "u3", "alice", 0, ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := oidcLogin(t, issuer, tt.subject, tt.username)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if user.ID != tt.wantID || user.Username != "bob" {
+				t.Fatalf("user = %d/%s, want %d/bob", user.ID, user.Username, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// claims 修改 ID Token 声明
+		claims func(jwt.MapClaims)
+		// kid 签名使用的 kid
+		kid string
+		// cookie 替换浏览器中的 state cookie
+		cookie func(state string) string
+		// badCode 使用伪造的授权码，令牌接口拒绝换取
+		badCode bool
+		wantErr error
+	}{
+		{name: "nonce mismatch", claims: func(c jwt.MapClaims) { c["nonce"] = "other" }, wantErr: ErrOIDCIDToken},
+		{name: "missing nonce", claims: func(c jwt.MapClaims) { delete(c, "nonce") }, wantErr: ErrOIDCIDToken},
+		{name: "wrong audience", claims: func(c jwt.MapClaims) { c["aud"] = "someone-else" }, wantErr: ErrOIDCIDToken},
+		{name: "wrong issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.test" }, wantErr: ErrOIDCIDToken},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, wantErr: ErrOIDCIDToken},
+		{name: "missing exp", claims: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: ErrOIDCIDToken},
+		{name: "azp mismatch", claims: func(c jwt.MapClaims) {
+			c["aud"] = []string{"gpanel", "other"}
+			c["azp"] = "other"
+		}, wantErr: ErrOIDCIDToken},
+		{name: "unknown key", kid: "k2", wantErr: ErrOIDCIDToken},
+		{name: "missing state cookie", cookie: func(string) string { return "" }, wantErr: ErrOIDCState},
+		{name: "state cookie from another login", cookie: func(string) string { return OIDCStateCookieValue("attacker") }, wantErr: ErrOIDCState},
+		{name: "missing subject", claims: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: ErrOIDCIDToken},
+		{name: "unmapped role", claims: func(c jwt.MapClaims) { c["groups"] = []string{"guests"} }, wantErr: ErrInvalidCredentials},
+		{name: "token exchange rejected", badCode: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := setupOIDC(t)
+			issuer.claims = tt.claims
+			if tt.kid != "" {
+				issuer.kid = tt.kid
+			}
+			svc := NewOIDCService()
+			ctx := context.Background()
+
+			authURL, state, err := svc.AuthorizationURL(ctx)
+			if err != nil {
+				t.Fatalf("AuthorizationURL: %v", err)
+			}
+			code, _ := issuer.authorize(t, authURL)
+			if tt.badCode {
+				code = "forged"
+			}
+			cookie := OIDCStateCookieValue(state)
+			if tt.cookie != nil {
+				cookie = tt.cookie(state)
+			}
+
+			user, err := svc.HandleCallback(ctx, code, state, cookie)
+			if err == nil {
+				t.Fatalf("HandleCallback succeeded for %s", user.Username)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCConfigRequirements(t *testing.T) {
+	t.Run("redirect url required", func(t *testing.T) {
+		setupOIDC(t)
+		setTestSettings(t, map[string]string{"OIDCRedirectURL": ""})
+		if _, _, err := NewOIDCService().AuthorizationURL(context.Background()); !errors.Is(err, ErrOIDCRedirectURL) {
+			t.Fatalf("err = %v, want ErrOIDCRedirectURL", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		setupOIDC(t)
+		setTestSettings(t, map[string]string{"OIDCEnabled": "false"})
+		if _, _, err := NewOIDCService().AuthorizationURL(context.Background()); !errors.Is(err, ErrOIDCDisabled) {
+			t.Fatalf("err = %v, want ErrOIDCDisabled", err)
+		}
+	})
+
+	t.Run("discovery issuer mismatch", func(t *testing.T) {
+		issuer := setupOIDC(t)
+		issuer.issuer = "https://evil.test"
+		if _, _, err := NewOIDCService().AuthorizationURL(context.Background()); err == nil {
+			t.Fatal("AuthorizationURL accepted a discovery document for another issuer")
+		}
+	})
+}
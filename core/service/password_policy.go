@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/utils"
+)
+
+// defaultAdminPassword 初始管理员密码，使用该密码登录后必须先修改密码
+const defaultAdminPassword = "admin123"
+
+var (
+	ErrWeakPassword      = errors.New("password does not meet the password policy")
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrPasswordReused    = errors.New("new password must be different from the current password")
+)
+
+// CurrentPasswordPolicy 根据当前配置生成密码策略
+func CurrentPasswordPolicy() utils.PasswordPolicy {
+	policy := utils.PasswordPolicy{MinLength: 8}
+	if global.ConfigCacheInstance != nil {
+		policy.MinLength = global.ConfigCacheInstance.GetPasswordMinLength()
+		policy.RequireComplexity = global.ConfigCacheInstance.GetPasswordComplexityCheck()
+	}
+	if policy.MinLength < 1 {
+		policy.MinLength = 1
+	}
+	return policy
+}
+
+// ValidatePassword 按当前密码策略校验新密码
+func ValidatePassword(password, username string) error {
+	if err := CurrentPasswordPolicy().Validate(password, username); err != nil {
+		return fmt.Errorf("%w: %v", ErrWeakPassword, err)
+	}
+	return nil
+}
+
+// PasswordExpired 密码是否超过最长使用天数，从未修改过的按创建时间计算
+func PasswordExpired(user *models.User) bool {
+	if global.ConfigCacheInstance == nil {
+		return false
+	}
+	maxAge := global.ConfigCacheInstance.GetPasswordMaxAge()
+	if maxAge <= 0 {
+		return false
+	}
+
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return time.Since(changedAt) > time.Duration(maxAge)*24*time.Hour
+}
+
+// MustChangePassword 用户登录后是否必须先修改密码，外部认证的账户不受密码策略约束
+func MustChangePassword(user *models.User) bool {
+	if !user.IsLocal() {
+		return false
+	}
+	return user.MustChangePassword || PasswordExpired(user)
+}
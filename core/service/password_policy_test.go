@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gpanel/models"
+	"gpanel/utils"
+)
+
+func TestPasswordExpired(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+	daysAgo := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+
+	tests := []struct {
+		name      string
+		maxAge    string
+		createdAt time.Time
+		changedAt *time.Time
+		mustFlag  bool
+		want      bool
+	}{
+		{"no max age", "0", now.AddDate(-1, 0, 0), nil, false, false},
+		{"changed recently", "90", now.AddDate(-1, 0, 0), daysAgo(10), false, false},
+		{"changed too long ago", "90", now, daysAgo(91), false, true},
+		{"never changed uses creation time", "90", now.AddDate(0, 0, -91), nil, false, true},
+		{"flagged by admin", "0", now, daysAgo(1), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestSettings(t, map[string]string{"PasswordMaxAge": tt.maxAge})
+			user := &models.User{PasswordChangedAt: tt.changedAt, MustChangePassword: tt.mustFlag}
+			user.CreatedAt = tt.createdAt
+			if got := MustChangePassword(user); got != tt.want {
+				t.Fatalf("MustChangePassword = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserService()
+
+	// 使用默认密码登录后必须修改密码
+	hash, err := utils.HashPassword(defaultAdminPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &models.User{Username: "admin", PasswordHash: hash, Role: models.RoleAdmin}
+	if err := userRepo.Create(admin); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := users.Authenticate("admin", defaultAdminPassword); err != nil || !MustChangePassword(user) {
+		t.Fatalf("default password login: %+v, %v, want a forced password change", user, err)
+	}
+	session, _, err := NewSessionService().Create(admin, "10.0.0.1", "ua", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 按顺序执行，最后一步修改成功
+	tests := []struct {
+		name    string
+		current string
+		next    string
+		wantErr error
+	}{
+		{"wrong current password", "wrong", "Str0ng-Passw0rd", ErrIncorrectPassword},
+		{"same password", defaultAdminPassword, defaultAdminPassword, ErrPasswordReused},
+		{"weak password", defaultAdminPassword, "short", ErrWeakPassword},
+		{"common password", defaultAdminPassword, "password", ErrWeakPassword},
+		{"valid change", defaultAdminPassword, "Str0ng-Passw0rd", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := users.ChangePassword(admin.ID, tt.current, tt.next); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	user, err := users.Authenticate("admin", "Str0ng-Passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if MustChangePassword(user) || user.PasswordChangedAt == nil {
+		t.Fatalf("password change did not clear the forced rotation: %+v", user)
+	}
+	if _, err := NewSessionService().Validate(session.JTI); err == nil {
+		t.Fatal("sessions should be revoked after a password change")
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/repo"
+)
+
+// sessionTouchInterval 最近活动时间的最小更新间隔
+const sessionTouchInterval = time.Minute
+
+// sessionRetention 过期会话保留时间，之后从数据库清理
+const sessionRetention = 7 * 24 * time.Hour
+
+// refreshReuseGrace 刷新令牌使用后仍允许再次使用的时间，多个标签页同时刷新时不视为令牌泄露
+const refreshReuseGrace = 10 * time.Second
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionInvalid      = errors.New("session is expired or revoked")
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrSessionConfigStale  = errors.New("configuration has been reloaded since login")
+	// ErrRefreshTokenRetry 令牌刚被并发请求轮换，客户端应使用并发请求拿到的新令牌重试
+	ErrRefreshTokenRetry = errors.New("refresh token was just rotated, retry with the new token")
+)
+
+type SessionService struct{}
+
+type ISessionService interface {
+	Create(user *models.User, ip, userAgent string, expiresAt time.Time) (*models.Session, string, error)
+	Refresh(refreshToken string) (*models.Session, string, error)
+	Validate(jti string) (*models.Session, error)
+	ListActive(userID uint) ([]models.Session, error)
+	ListAllActive() ([]models.Session, error)
+	Revoke(id uint, userID uint, anyUser bool) error
+	RevokeByJTI(jti string) error
+	RevokeByUser(userID uint) error
+}
+
+func NewSessionService() ISessionService {
+	return &SessionService{}
+}
+
+var (
+	sessionRepo      repo.ISessionRepo      = repo.NewSessionRepo()
+	refreshTokenRepo repo.IRefreshTokenRepo = repo.NewRefreshTokenRepo()
+)
+
+// idleExpiry 计算空闲过期时间，不超过会话的绝对过期时间
+func idleExpiry(now, expiresAt time.Time) time.Time {
+	idleTimeout := 7200
+	if global.ConfigCacheInstance != nil {
+		idleTimeout = global.ConfigCacheInstance.GetSessionIdleTimeout()
+	}
+	if idleTimeout <= 0 {
+		return expiresAt
+	}
+
+	idleExpiresAt := now.Add(time.Duration(idleTimeout) * time.Second)
+	if idleExpiresAt.After(expiresAt) {
+		return expiresAt
+	}
+	return idleExpiresAt
+}
+
+// Create 登记新会话并签发刷新令牌，返回的 jti 写入 JWT
+func (s *SessionService) Create(user *models.User, ip, userAgent string, expiresAt time.Time) (*models.Session, string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		JTI:           hex.EncodeToString(b),
+		UserID:        user.ID,
+		Username:      user.Username,
+		IP:            ip,
+		UserAgent:     userAgent,
+		LastSeenAt:    now,
+		IdleExpiresAt: idleExpiry(now, expiresAt),
+		ExpiresAt:     expiresAt,
+		ConfigVersion: currentConfigVersion(),
+	}
+	if err := sessionRepo.Create(session); err != nil {
+		return nil, "", err
+	}
+
+	refreshToken, err := issueRefreshToken(session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := sessionRepo.DeleteExpired(now.Add(-sessionRetention)); err != nil {
+		log.Printf("Failed to clean up expired sessions: %v", err)
+	} else if err := refreshTokenRepo.DeleteOrphaned(); err != nil {
+		log.Printf("Failed to clean up refresh tokens: %v", err)
+	}
+	return session, refreshToken, nil
+}
+
+func issueRefreshToken(sessionID uint) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	raw := base64.RawURLEncoding.EncodeToString(b)
+	if err := refreshTokenRepo.Create(&models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashAPIToken(raw),
+	}); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Refresh 使用刷新令牌换发新令牌并顺延空闲过期时间。
+// 已使用过的令牌再次出现说明令牌可能被窃取，立即吊销整个会话；
+// 使用后 refreshReuseGrace 内再次出现视为并发刷新，不换发新令牌，返回 ErrRefreshTokenRetry。
+// 登录后配置重新加载过的会话不再续期，与访问 token 的配置版本校验保持一致
+func (s *SessionService) Refresh(refreshToken string) (*models.Session, string, error) {
+	token, err := refreshTokenRepo.GetByHash(hashAPIToken(refreshToken))
+	if err != nil {
+		return nil, "", ErrRefreshTokenInvalid
+	}
+
+	now := time.Now()
+	usedAt := token.UsedAt
+	if usedAt == nil {
+		ok, err := refreshTokenRepo.MarkUsed(token.ID, now)
+		if err != nil {
+			return nil, "", err
+		}
+		if !ok {
+			// 并发请求刚刚使用了该令牌
+			usedAt = &now
+		}
+	}
+	if usedAt != nil {
+		if now.Sub(*usedAt) > refreshReuseGrace {
+			revokeReusedSession(token.SessionID, now)
+			return nil, "", ErrRefreshTokenReused
+		}
+		// 每个令牌只换发一个后继令牌，否则被窃取的令牌可在宽限期内换出一条并行的令牌链
+		return nil, "", ErrRefreshTokenRetry
+	}
+
+	session, err := sessionRepo.GetByID(token.SessionID)
+	if err != nil || !sessionActive(session, now) {
+		return nil, "", ErrSessionInvalid
+	}
+	if session.ConfigVersion != currentConfigVersion() {
+		if err := sessionRepo.Revoke(session.ID, now); err != nil {
+			log.Printf("Failed to revoke session %d: %v", session.ID, err)
+		}
+		return nil, "", ErrSessionConfigStale
+	}
+
+	session.IdleExpiresAt = idleExpiry(now, session.ExpiresAt)
+	session.LastSeenAt = now
+	if err := sessionRepo.Save(session); err != nil {
+		return nil, "", err
+	}
+
+	newToken, err := issueRefreshToken(session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, newToken, nil
+}
+
+// currentConfigVersion 当前配置版本，配置缓存未初始化时为空
+func currentConfigVersion() string {
+	if global.ConfigCacheInstance == nil {
+		return ""
+	}
+	return global.ConfigCacheInstance.GetVersion()
+}
+
+func revokeReusedSession(sessionID uint, now time.Time) {
+	log.Printf("Refresh token reuse detected, revoking session %d", sessionID)
+	if err := sessionRepo.Revoke(sessionID, now); err != nil {
+		log.Printf("Failed to revoke session %d: %v", sessionID, err)
+	}
+	if err := refreshTokenRepo.DeleteBySession(sessionID); err != nil {
+		log.Printf("Failed to delete refresh tokens of session %d: %v", sessionID, err)
+	}
+}
+
+// sessionActive 会话未被吊销，且未超过绝对过期时间和空闲过期时间
+func sessionActive(session *models.Session, now time.Time) bool {
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return false
+	}
+	return session.IdleExpiresAt.IsZero() || now.Before(session.IdleExpiresAt)
+}
+
+// Validate 校验会话未被吊销且未过期，并更新最近活动时间
+func (s *SessionService) Validate(jti string) (*models.Session, error) {
+	session, err := sessionRepo.GetByJTI(jti)
+	if err != nil {
+		return nil, ErrSessionInvalid
+	}
+
+	now := time.Now()
+	if !sessionActive(session, now) {
+		return nil, ErrSessionInvalid
+	}
+
+	if now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		if err := sessionRepo.UpdateLastSeen(session.ID, now); err != nil {
+			log.Printf("Failed to update session last seen time: %v", err)
+		}
+		session.LastSeenAt = now
+	}
+	return session, nil
+}
+
+func (s *SessionService) ListActive(userID uint) ([]models.Session, error) {
+	return sessionRepo.ListActiveByUser(userID, time.Now())
+}
+
+func (s *SessionService) ListAllActive() ([]models.Session, error) {
+	return sessionRepo.ListActive(time.Now())
+}
+
+// Revoke 吊销会话，anyUser 为 true 时可吊销其他用户的会话
+func (s *SessionService) Revoke(id uint, userID uint, anyUser bool) error {
+	session, err := sessionRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if !anyUser && session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return sessionRepo.Revoke(id, time.Now())
+}
+
+func (s *SessionService) RevokeByJTI(jti string) error {
+	session, err := sessionRepo.GetByJTI(jti)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+	return sessionRepo.Revoke(session.ID, time.Now())
+}
+
+func (s *SessionService) RevokeByUser(userID uint) error {
+	return sessionRepo.RevokeByUser(userID, time.Now())
+}
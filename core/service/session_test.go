@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+// createTestSession 为新用户创建一小时有效的会话，返回会话和刷新令牌
+func createTestSession(t *testing.T) (*models.Session, string) {
+	t.Helper()
+
+	user := &models.User{Username: "alice", Role: models.RoleAdmin}
+	if err := userRepo.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	session, refreshToken, err := NewSessionService().Create(user, "10.0.0.1", "ua", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session, refreshToken
+}
+
+func TestRefreshConcurrentWithinGrace(t *testing.T) {
+	setupTestDB(t)
+	session, refreshToken := createTestSession(t)
+	sessions := NewSessionService()
+
+	// 多个标签页同时使用同一个刷新令牌，只有一个请求换发新令牌，其余请求重试，不应吊销会话
+	var wg sync.WaitGroup
+	tokens := make([]string, 5)
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, tokens[i], errs[i] = sessions.Refresh(refreshToken)
+		}(i)
+	}
+	wg.Wait()
+
+	var successor string
+	for i, err := range errs {
+		switch {
+		case err == nil && successor == "":
+			successor = tokens[i]
+		case err == nil:
+			t.Fatal("more than one successor issued for the same refresh token")
+		case !errors.Is(err, ErrRefreshTokenRetry):
+			t.Fatalf("refresh %d: %v", i, err)
+		}
+	}
+	if successor == "" {
+		t.Fatal("no successor issued")
+	}
+	if _, err := sessions.Validate(session.JTI); err != nil {
+		t.Fatalf("session revoked by concurrent refresh: %v", err)
+	}
+	// 重试时使用后继令牌
+	if _, _, err := sessions.Refresh(successor); err != nil {
+		t.Fatalf("refresh with successor: %v", err)
+	}
+}
+
+func TestRefreshReuseAfterGraceRevokesSession(t *testing.T) {
+	setupTestDB(t)
+	session, refreshToken := createTestSession(t)
+	sessions := NewSessionService()
+
+	if _, _, err := sessions.Refresh(refreshToken); err != nil {
+		t.Fatal(err)
+	}
+	usedAt := time.Now().Add(-refreshReuseGrace - time.Second)
+	if err := global.DB.Model(&models.RefreshToken{}).Where("session_id = ?", session.ID).
+		Where("used_at IS NOT NULL").Update("used_at", usedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := sessions.Refresh(refreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("err = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := sessions.Validate(session.JTI); err == nil {
+		t.Fatal("session should be revoked after refresh token reuse")
+	}
+}
+
+func TestRefreshRejectsStaleConfigVersion(t *testing.T) {
+	setupTestDB(t)
+	session, refreshToken := createTestSession(t)
+	if session.ConfigVersion != global.ConfigCacheInstance.GetVersion() {
+		t.Fatalf("session config version = %q, want current version", session.ConfigVersion)
+	}
+
+	if err := global.DB.Model(&models.Session{}).Where("id = ?", session.ID).
+		Update("config_version", "stale").Error; err != nil {
+		t.Fatal(err)
+	}
+	sessions := NewSessionService()
+	if _, _, err := sessions.Refresh(refreshToken); !errors.Is(err, ErrSessionConfigStale) {
+		t.Fatalf("err = %v, want ErrSessionConfigStale", err)
+	}
+	if _, err := sessions.Validate(session.JTI); err == nil {
+		t.Fatal("stale session should be revoked")
+	}
+}
+
+func TestSessionValidateExpiry(t *testing.T) {
+	setupTestDB(t)
+	session, _ := createTestSession(t)
+	sessions := NewSessionService()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		update  map[string]interface{}
+		wantErr bool
+	}{
+		{"active", map[string]interface{}{}, false},
+		{"idle timeout passed", map[string]interface{}{"idle_expires_at": now.Add(-time.Minute)}, true},
+		{"absolute expiry passed", map[string]interface{}{"expires_at": now.Add(-time.Minute)}, true},
+		{"no idle timeout", map[string]interface{}{"idle_expires_at": time.Time{}}, false},
+		{"revoked", map[string]interface{}{"revoked_at": now}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 每个用例从一小时有效、未吊销的会话开始
+			reset := map[string]interface{}{
+				"expires_at":      now.Add(time.Hour),
+				"idle_expires_at": now.Add(time.Hour),
+				"revoked_at":      nil,
+			}
+			for key, value := range tt.update {
+				reset[key] = value
+			}
+			if err := global.DB.Model(&models.Session{}).Where("id = ?", session.ID).Updates(reset).Error; err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sessions.Validate(session.JTI); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionIdleExpiry(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		idleTimeout string
+		expiresAt   time.Time
+		want        time.Time
+	}{
+		{"idle timeout before absolute expiry", "600", now.Add(time.Hour), now.Add(10 * time.Minute)},
+		{"capped at absolute expiry", "7200", now.Add(time.Hour), now.Add(time.Hour)},
+		{"idle timeout disabled", "0", now.Add(time.Hour), now.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestSettings(t, map[string]string{"SessionIdleTimeout": tt.idleTimeout})
+			if got := idleExpiry(now, tt.expiresAt); !got.Equal(tt.want) {
+				t.Fatalf("idleExpiry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	setupTestDB(t)
+	session, refreshToken := createTestSession(t)
+	sessions := NewSessionService()
+
+	tests := []struct {
+		name    string
+		userID  uint
+		anyUser bool
+		wantErr error
+	}{
+		{"other user", session.UserID + 1, false, ErrSessionNotFound},
+		{"admin revokes any user", session.UserID + 1, true, nil},
+		{"owner revokes again", session.UserID, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sessions.Revoke(session.ID, tt.userID, tt.anyUser); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := sessions.Revoke(session.ID+100, session.UserID, true); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("missing session: err = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := sessions.Validate(session.JTI); !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("revoked session: err = %v, want ErrSessionInvalid", err)
+	}
+	if _, _, err := sessions.Refresh(refreshToken); !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("refresh revoked session: err = %v, want ErrSessionInvalid", err)
+	}
+	if active, err := sessions.ListActive(session.UserID); err != nil || len(active) != 0 {
+		t.Fatalf("active sessions = %d, %v, want none", len(active), err)
+	}
+}
+
+func TestRefreshReuseDetection(t *testing.T) {
+	tests := []struct {
+		name        string
+		usedAgo     time.Duration // 0 表示令牌尚未使用
+		wantErr     error
+		wantRevoked bool
+	}{
+		{"unused token", 0, nil, false},
+		{"reused just now", time.Second, ErrRefreshTokenRetry, false},
+		{"reused at end of grace", refreshReuseGrace - time.Second, ErrRefreshTokenRetry, false},
+		{"reused after grace", refreshReuseGrace + time.Second, ErrRefreshTokenReused, true},
+		{"reused long after", time.Hour, ErrRefreshTokenReused, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			session, refreshToken := createTestSession(t)
+			sessions := NewSessionService()
+
+			// 先换发一次，拿到同一会话的另一个有效令牌
+			var sibling string
+			if tt.usedAgo > 0 {
+				var err error
+				if _, sibling, err = sessions.Refresh(refreshToken); err != nil {
+					t.Fatal(err)
+				}
+				if err := global.DB.Model(&models.RefreshToken{}).Where("session_id = ? AND used_at IS NOT NULL", session.ID).
+					Update("used_at", time.Now().Add(-tt.usedAgo)).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, newToken, err := sessions.Refresh(refreshToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (newToken == "" || newToken == refreshToken) {
+				t.Fatal("refresh should issue a new token")
+			}
+
+			_, validateErr := sessions.Validate(session.JTI)
+			if revoked := validateErr != nil; revoked != tt.wantRevoked {
+				t.Fatalf("revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+			// 会话被吊销后，同一会话已换发的令牌也不能再用
+			if tt.wantRevoked {
+				if _, _, err := sessions.Refresh(sibling); !errors.Is(err, ErrRefreshTokenInvalid) {
+					t.Fatalf("sibling token: err = %v, want ErrRefreshTokenInvalid", err)
+				}
+			}
+		})
+	}
+
+	setupTestDB(t)
+	if _, _, err := NewSessionService().Refresh("unknown"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("unknown token: err = %v, want ErrRefreshTokenInvalid", err)
+	}
+}
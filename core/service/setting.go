@@ -2,10 +2,17 @@ package service
 
 import (
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"math/big"
+	"sort"
 
+	"gorm.io/gorm"
+
+	"gpanel/global"
 	"gpanel/models"
 	"gpanel/repo"
+	"gpanel/utils"
 )
 
 type SettingService struct{}
@@ -14,9 +21,10 @@ type ISettingService interface {
 	GetAllSettings() ([]models.Setting, error)
 	GetSettingByKey(key string) (*models.Setting, error)
 	GetSettingValueByKey(key string) (string, error)
-	UpdateSetting(key, value string) error
-	CreateSetting(key, value, about string) error
-	DeleteSetting(key string) error
+	UpdateSetting(key, value string, actor SettingActor) error
+	UpdateSettings(updates map[string]string, actor SettingActor) ([]string, error)
+	CreateSetting(key, value, about string, secret bool, actor SettingActor) error
+	DeleteSetting(key string, actor SettingActor) error
 	InitializeDefaultSettings() error
 }
 
@@ -26,6 +34,141 @@ func NewSettingService() ISettingService {
 
 var settingRepo repo.ISettingRepo = repo.NewSettingRepo()
 
+// secretMask 敏感设置在接口中的显示值，更新时提交该值表示保持不变
+const secretMask = "********"
+
+// credentialKeys 存储凭据的设置项，只保存哈希且不会通过 API 返回
+var credentialKeys = map[string]bool{
+	"PanelPassword": true,
+}
+
+// IsCredentialKey 判断设置项是否为凭据
+func IsCredentialKey(key string) bool {
+	return credentialKeys[key]
+}
+
+// isSecretKey 设置项定义中标记为密钥，使用主密钥加密保存，同样不会通过 API 返回
+func isSecretKey(key string) bool {
+	schema, ok := LookupSettingSchema(key)
+	return ok && schema.Secret
+}
+
+// IsSensitiveKey 判断设置项是否为凭据或密钥，包括创建时标记为敏感的自定义设置项
+func IsSensitiveKey(key string) bool {
+	if credentialKeys[key] || isSecretKey(key) {
+		return true
+	}
+	return global.ConfigCacheInstance != nil && global.ConfigCacheInstance.IsSecret(key)
+}
+
+// ValidateSettingUpdates 按设置项定义校验批量修改，返回所有不合法的设置项。
+// 未定义的设置项只有已通过创建接口添加过才允许修改
+func ValidateSettingUpdates(updates map[string]string) error {
+	return validateSettingUpdates(updates, nil)
+}
+
+// validateSettingUpdates 同 ValidateSettingUpdates，custom 中的自定义设置项允许不存在
+func validateSettingUpdates(updates map[string]string, custom map[string]BundleCustomSetting) error {
+	fields := make(map[string]string)
+	for key, value := range updates {
+		if _, ok := custom[key]; ok {
+			continue
+		}
+		// 被配置文件或环境变量覆盖的设置写入数据库也不会生效，只允许提交当前生效值
+		if source, ok := global.ConfigOverride(key); ok && value != source.Value {
+			fields[key] = overriddenMessage(source)
+			continue
+		}
+		if err := validateSettingValue(key, value); err != nil {
+			fields[key] = err.Error()
+		}
+	}
+	if len(fields) > 0 {
+		return &SettingValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// overriddenMessage 提示覆盖设置的配置项，需在配置文件或环境变量中修改
+func overriddenMessage(source global.ConfigSource) string {
+	if source.Source == global.ConfigSourceEnv {
+		return fmt.Sprintf("set by environment variable %s, change it there", source.Env)
+	}
+	return fmt.Sprintf("set by %s in the config file, change it there", source.Key)
+}
+
+func validateSettingValue(key, value string) error {
+	schema, ok := LookupSettingSchema(key)
+	if !ok {
+		if _, err := settingRepo.GetByKey(key); err != nil {
+			return ErrUnknownSetting
+		}
+		return nil
+	}
+	// 提交掩码表示不修改密钥
+	if schema.Secret && value == secretMask {
+		return nil
+	}
+	return schema.Validate(value)
+}
+
+// maskSecret 敏感设置只显示是否已设置
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return secretMask
+}
+
+// PublicSettingMap 将凭据和密钥类设置项替换为掩码
+func PublicSettingMap(settings map[string]string) map[string]string {
+	result := make(map[string]string, len(settings))
+	for k, v := range settings {
+		if IsSensitiveKey(k) {
+			v = maskSecret(v)
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// PublicSetting 将凭据和密钥类设置项的值替换为掩码
+func PublicSetting(setting models.Setting) models.Setting {
+	if setting.Secret || IsSensitiveKey(setting.Key) {
+		setting.Secret = true
+		setting.Value = maskSecret(setting.Value)
+	}
+	return setting
+}
+
+// PublicSettings 将凭据和密钥类设置项的值替换为掩码
+func PublicSettings(settings []models.Setting) []models.Setting {
+	result := make([]models.Setting, 0, len(settings))
+	for _, setting := range settings {
+		result = append(result, PublicSetting(setting))
+	}
+	return result
+}
+
+// prepareSettingValue 返回写入数据库和配置缓存的值：凭据类设置项转换为密码哈希，密钥类设置项加密后写入数据库
+func prepareSettingValue(key, value string, secret bool) (string, string, error) {
+	if IsCredentialKey(key) {
+		if utils.IsPasswordHash(value) {
+			return value, value, nil
+		}
+		if value == "" {
+			return "", "", errors.New("password cannot be empty")
+		}
+		hash, err := utils.HashPassword(value)
+		return hash, hash, err
+	}
+	if secret {
+		encrypted, err := global.EncryptSecret(value)
+		return encrypted, value, err
+	}
+	return value, value, nil
+}
+
 func (s *SettingService) GetAllSettings() ([]models.Setting, error) {
 	return settingRepo.List()
 }
@@ -34,29 +177,142 @@ func (s *SettingService) GetSettingByKey(key string) (*models.Setting, error) {
 	return settingRepo.GetByKey(key)
 }
 
+// GetSettingValueByKey 返回设置值，敏感设置返回解密后的值，仅供服务端内部使用
 func (s *SettingService) GetSettingValueByKey(key string) (string, error) {
-	return settingRepo.GetValueByKey(key)
+	setting, err := settingRepo.GetByKey(key)
+	if err != nil {
+		return "", err
+	}
+	if setting.Secret {
+		return global.DecryptSecret(setting.Value)
+	}
+	return setting.Value, nil
 }
 
-func (s *SettingService) UpdateSetting(key, value string) error {
-	oldSetting, err := settingRepo.GetByKey(key)
-	if err != nil {
-		// 如果设置不存在，则创建它
-		_ = settingRepo.Create(key, value, "")
-		return nil
+func (s *SettingService) UpdateSetting(key, value string, actor SettingActor) error {
+	_, err := s.UpdateSettings(map[string]string{key: value}, actor)
+	return err
+}
+
+// UpdateSettings 在同一事务中批量修改设置，全部写入成功后才一次性更新配置缓存，返回实际发生变化的设置项
+func (s *SettingService) UpdateSettings(updates map[string]string, actor SettingActor) ([]string, error) {
+	if err := ValidateSettingUpdates(updates); err != nil {
+		return nil, err
 	}
-	if oldSetting.Value == value {
-		return nil
+	return writeSettingUpdates(updates, nil, actor)
+}
+
+// writeSettingUpdates 写入已校验的批量修改，custom 中不存在的自定义设置项按其说明创建，标记为敏感的加密保存
+func writeSettingUpdates(updates map[string]string, custom map[string]BundleCustomSetting, actor SettingActor) ([]string, error) {
+	keys := make([]string, 0, len(updates))
+	for key := range updates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writes := make([]settingWrite, 0, len(keys))
+	changed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := updates[key]
+		// 覆盖的设置已校验为当前生效值，数据库中的值不生效，无需写入
+		if _, ok := global.ConfigOverride(key); ok {
+			continue
+		}
+		oldSetting, err := settingRepo.GetByKey(key)
+		if err != nil {
+			oldSetting = nil
+		}
+		secret := IsSensitiveKey(key) || (oldSetting != nil && oldSetting.Secret) || custom[key].Secret
+		if secret && value == secretMask {
+			continue
+		}
+		// 导出包将已有的自定义设置项标记为敏感时，即使值相同也要改为加密保存
+		encrypt := custom[key].Secret && oldSetting != nil && !oldSetting.Secret
+		if oldSetting != nil && !settingValueChanged(oldSetting, value) && !encrypt {
+			continue
+		}
+
+		stored, cached, err := prepareSettingValue(key, value, secret)
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, settingWrite{key: key, stored: stored, cached: cached, about: custom[key].About, secret: secret, old: oldSetting})
+		changed = append(changed, key)
+	}
+
+	if err := commitSettingWrites(writes, actor); err != nil {
+		return nil, err
 	}
-	return settingRepo.Update(key, value)
+	return changed, nil
 }
 
-func (s *SettingService) CreateSetting(key, value, about string) error {
-	return settingRepo.Create(key, value, about)
+// settingValueChanged 判断新值与数据库中的值是否不同，凭据比较哈希，密钥比较解密后的值
+func settingValueChanged(setting *models.Setting, value string) bool {
+	if setting.Value == value {
+		return false
+	}
+	if IsCredentialKey(setting.Key) {
+		return true
+	}
+	if setting.Secret {
+		if current, err := global.DecryptSecret(setting.Value); err == nil && current == value {
+			return false
+		}
+	}
+	return true
 }
 
-func (s *SettingService) DeleteSetting(key string) error {
-	return settingRepo.Delete(key)
+// ChangedSecuritySettings 返回批量修改中值会发生变化的安全设置项，提交原值或密钥掩码不算修改
+func ChangedSecuritySettings(updates map[string]string) []string {
+	var keys []string
+	for key, value := range updates {
+		if !IsSecuritySetting(key) {
+			continue
+		}
+		if IsSensitiveKey(key) && value == secretMask {
+			continue
+		}
+		if setting, err := settingRepo.GetByKey(key); err == nil && !settingValueChanged(setting, value) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CreateSetting 创建设置项，secret 为 true 时加密保存且不会通过 API 返回
+func (s *SettingService) CreateSetting(key, value, about string, secret bool, actor SettingActor) error {
+	if schema, ok := LookupSettingSchema(key); ok {
+		if err := schema.Validate(value); err != nil {
+			return &SettingValidationError{Fields: map[string]string{key: err.Error()}}
+		}
+	}
+	if _, err := settingRepo.GetByKey(key); err == nil {
+		return &SettingValidationError{Fields: map[string]string{key: "setting already exists"}}
+	}
+
+	secret = secret || IsSensitiveKey(key)
+	stored, cached, err := prepareSettingValue(key, value, secret)
+	if err != nil {
+		return err
+	}
+	return commitSettingWrites([]settingWrite{{key: key, stored: stored, cached: cached, about: about, secret: secret}}, actor)
+}
+
+// DeleteSetting 删除自定义设置项，内置设置项只能修改
+func (s *SettingService) DeleteSetting(key string, actor SettingActor) error {
+	if _, ok := LookupSettingSchema(key); ok {
+		return ErrBuiltInSetting
+	}
+	oldSetting, err := settingRepo.GetByKey(key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSettingNotFound
+		}
+		return err
+	}
+	return commitSettingWrites([]settingWrite{{key: key, secret: oldSetting.Secret, deleted: true, old: oldSetting}}, actor)
 }
 
 func (s *SettingService) InitializeDefaultSettings() error {
@@ -70,31 +326,45 @@ func (s *SettingService) InitializeDefaultSettings() error {
 		securityEntrance = generateRandomEntrance()
 	}
 
-	defaultSettings := map[string]struct {
-		Value string
-		About string
-	}{
-		"ServerPort":               {"8080", "服务器端口"},
-		"ServerMode":               {"debug", "服务器运行模式"},
-		"SecurityEntrance":         {securityEntrance, "安全入口路径"},
-		"Initialized":              {"true", "系统是否已初始化"},
-		"Language":                 {"zh-CN", "系统语言"},
-		"Timezone":                 {"Asia/Shanghai", "时区设置"},
-		"PanelUser":                {"admin", "面板用户名"},
-		"PanelPassword":            {"admin123", "面板密码"},
-		"SessionTimeout":           {"86400", "会话超时时间（秒）"},
-		"ServerAddress":            {"", "服务器地址"},
-		"ListenAddress":            {"0.0.0.0", "监听地址"},
-		"PasswordComplexityCheck":  {"false", "密码复杂度验证"},
-	}
-
-	for key, setting := range defaultSettings {
-		_, err := settingRepo.GetByKey(key)
-		if err != nil {
-			_ = settingRepo.Create(key, setting.Value, setting.About)
+	for _, schema := range settingSchemas {
+		if _, err := settingRepo.GetByKey(schema.Key); err == nil {
+			continue
+		}
+		value := schema.Default
+		if schema.Key == "SecurityEntrance" {
+			value = securityEntrance
 		}
+		_ = settingRepo.Create(schema.Key, value, schema.Description, schema.Secret)
 	}
 
+	return encryptSecretSettings()
+}
+
+// encryptSecretSettings 将旧版本明文保存的凭据和密钥类设置项标记为敏感设置并加密
+func encryptSecretSettings() error {
+	settings, err := settingRepo.List()
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range settings {
+		if !credentialKeys[setting.Key] && !isSecretKey(setting.Key) && !setting.Secret {
+			continue
+		}
+		if setting.Secret && (IsCredentialKey(setting.Key) || setting.Value == "" || global.IsEncryptedSecret(setting.Value)) {
+			continue
+		}
+
+		value := setting.Value
+		if !IsCredentialKey(setting.Key) {
+			if value, err = global.EncryptSecret(setting.Value); err != nil {
+				return err
+			}
+		}
+		if err := settingRepo.MarkSecret(setting.Key, value); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -108,5 +378,10 @@ func generateRandomEntrance() string {
 		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
 		b[i] = charset[n.Int64()]
 	}
-	return "/" + string(b)
-}
\ No newline at end of file
+	entrance := "/" + string(b)
+	// 避开保留前缀和前端路由
+	if validateEntrancePath(entrance) != nil {
+		return generateRandomEntrance()
+	}
+	return entrance
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"gopkg.in/yaml.v3"
+
+	"gpanel/global"
+)
+
+// settingBundleVersion 当前导出包格式版本
+const settingBundleVersion = 1
+
+// bundleSecretPrefix 导出包中使用口令加密的设置值前缀
+const bundleSecretPrefix = "bundle:v1:"
+
+// minBundlePassphraseLength 加密导出包口令的最小长度
+const minBundlePassphraseLength = 8
+
+// 敏感设置的导出方式
+const (
+	BundleSecretsExclude = "exclude"
+	BundleSecretsEncrypt = "encrypt"
+)
+
+// 设置差异类型
+const (
+	SettingDiffAdd    = "add"
+	SettingDiffChange = "change"
+)
+
+var (
+	ErrInvalidBundle             = errors.New("invalid settings bundle")
+	ErrUnsupportedBundleSecrets  = errors.New("secrets must be exclude or encrypt")
+	ErrBundlePassphraseRequired  = errors.New("a passphrase of at least 8 characters is required for encrypted secrets")
+	ErrBundlePassphraseIncorrect = errors.New("incorrect bundle passphrase")
+)
+
+// SettingBundle 设置导出包，用于在多台服务器之间复制配置
+type SettingBundle struct {
+	Version    int       `json:"version" yaml:"version"`
+	ExportedAt time.Time `json:"exportedAt" yaml:"exportedAt"`
+	// Secrets 敏感设置的导出方式：exclude 不导出，encrypt 使用口令加密导出
+	Secrets string `json:"secrets" yaml:"secrets"`
+	// Salt 由口令派生加密密钥使用的盐，仅 encrypt 方式存在
+	Salt     string            `json:"salt,omitempty" yaml:"salt,omitempty"`
+	Settings map[string]string `json:"settings" yaml:"settings"`
+	// Custom 导出的自定义设置项，导入时目标服务器上不存在的按此创建
+	Custom map[string]BundleCustomSetting `json:"custom,omitempty" yaml:"custom,omitempty"`
+}
+
+// BundleCustomSetting 自定义设置项的说明，以及是否为创建时标记的敏感设置项
+type BundleCustomSetting struct {
+	About  string `json:"about,omitempty" yaml:"about,omitempty"`
+	Secret bool   `json:"secret,omitempty" yaml:"secret,omitempty"`
+}
+
+// SettingDiff 导入前后单个设置项的差异，敏感设置的值为掩码
+type SettingDiff struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// SettingImportResult 导入结果，DryRun 时只返回差异不写入
+type SettingImportResult struct {
+	DryRun  bool          `json:"dryRun"`
+	Changes []SettingDiff `json:"changes"`
+	Changed []string      `json:"changed"`
+}
+
+type SettingBundleService struct{}
+
+type ISettingBundleService interface {
+	Export(secrets, passphrase string) (*SettingBundle, error)
+	Import(values map[string]string, custom map[string]BundleCustomSetting, dryRun bool, actor SettingActor) (*SettingImportResult, error)
+}
+
+func NewSettingBundleService() ISettingBundleService {
+	return &SettingBundleService{}
+}
+
+// Export 导出数据库中的全部设置，凭据类设置项不会导出
+func (s *SettingBundleService) Export(secrets, passphrase string) (*SettingBundle, error) {
+	if secrets == "" {
+		secrets = BundleSecretsExclude
+	}
+	if secrets != BundleSecretsExclude && secrets != BundleSecretsEncrypt {
+		return nil, ErrUnsupportedBundleSecrets
+	}
+
+	bundle := &SettingBundle{
+		Version:    settingBundleVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Secrets:    secrets,
+		Settings:   make(map[string]string),
+		Custom:     make(map[string]BundleCustomSetting),
+	}
+
+	var aead cipher.AEAD
+	if secrets == BundleSecretsEncrypt {
+		if len(passphrase) < minBundlePassphraseLength {
+			return nil, ErrBundlePassphraseRequired
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		bundle.Salt = base64.StdEncoding.EncodeToString(salt)
+		var err error
+		if aead, err = bundleAEAD(passphrase, salt); err != nil {
+			return nil, err
+		}
+	}
+
+	settings, err := settingRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, setting := range settings {
+		if IsCredentialKey(setting.Key) {
+			continue
+		}
+		value := setting.Value
+		if setting.Secret || isSecretKey(setting.Key) {
+			if aead == nil {
+				continue
+			}
+			plaintext, err := global.DecryptSecret(setting.Value)
+			if err != nil {
+				return nil, err
+			}
+			if value, err = sealBundleValue(aead, plaintext); err != nil {
+				return nil, err
+			}
+		}
+		bundle.Settings[setting.Key] = value
+		if _, ok := LookupSettingSchema(setting.Key); !ok {
+			bundle.Custom[setting.Key] = BundleCustomSetting{About: setting.About, Secret: setting.Secret}
+		}
+	}
+	return bundle, nil
+}
+
+// Import 按普通修改相同的规则校验导入的设置并计算差异，dryRun 为 false 时在同一事务中写入。
+// custom 中列出的自定义设置项在目标服务器上不存在时会被创建，标记为敏感的加密保存
+func (s *SettingBundleService) Import(values map[string]string, custom map[string]BundleCustomSetting, dryRun bool, actor SettingActor) (*SettingImportResult, error) {
+	custom = importableCustomSettings(values, custom)
+	if err := validateSettingUpdates(values, custom); err != nil {
+		return nil, err
+	}
+
+	changes, err := diffSettings(values, custom)
+	if err != nil {
+		return nil, err
+	}
+	result := &SettingImportResult{DryRun: dryRun, Changes: changes, Changed: []string{}}
+	if dryRun {
+		return result, nil
+	}
+
+	if result.Changed, err = writeSettingUpdates(values, custom, actor); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importableCustomSettings 只保留导出包中确有取值的自定义设置项，内置设置项不能按自定义设置项创建
+func importableCustomSettings(values map[string]string, custom map[string]BundleCustomSetting) map[string]BundleCustomSetting {
+	result := make(map[string]BundleCustomSetting, len(custom))
+	for key, setting := range custom {
+		if _, ok := values[key]; !ok || IsCredentialKey(key) {
+			continue
+		}
+		if _, ok := LookupSettingSchema(key); ok {
+			continue
+		}
+		result[key] = setting
+	}
+	return result
+}
+
+// diffSettings 比较导入值与数据库中的当前值，只返回会发生变化的设置项
+func diffSettings(values map[string]string, custom map[string]BundleCustomSetting) ([]SettingDiff, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := make([]SettingDiff, 0)
+	for _, key := range keys {
+		value := values[key]
+		current, err := settingRepo.GetByKey(key)
+		if err != nil {
+			current = nil
+		}
+		sensitive := IsSensitiveKey(key) || (current != nil && current.Secret) || custom[key].Secret
+		if sensitive && value == secretMask {
+			continue
+		}
+		if current != nil && !settingValueChanged(current, value) {
+			continue
+		}
+
+		diff := SettingDiff{Key: key, Action: SettingDiffAdd, After: value}
+		if current != nil {
+			diff.Action = SettingDiffChange
+			diff.Before = current.Value
+		}
+		if sensitive {
+			diff.Before, diff.After = maskSecret(diff.Before), maskSecret(diff.After)
+		}
+		changes = append(changes, diff)
+	}
+	return changes, nil
+}
+
+// DecodeSettingBundle 解析 YAML 或 JSON 格式的导出包
+func DecodeSettingBundle(data []byte) (*SettingBundle, error) {
+	var bundle SettingBundle
+	if err := yaml.Unmarshal(data, &bundle); err != nil {
+		return nil, ErrInvalidBundle
+	}
+	if bundle.Version != settingBundleVersion || bundle.Settings == nil {
+		return nil, ErrInvalidBundle
+	}
+	return &bundle, nil
+}
+
+// EncodeSettingBundle 按 format（yaml 或 json）序列化导出包
+func EncodeSettingBundle(bundle *SettingBundle, format string) ([]byte, error) {
+	if format == "json" {
+		return json.MarshalIndent(bundle, "", "  ")
+	}
+	return yaml.Marshal(bundle)
+}
+
+// Values 返回导出包中的设置值，加密的敏感设置使用口令解密
+func (b *SettingBundle) Values(passphrase string) (map[string]string, error) {
+	var aead cipher.AEAD
+	values := make(map[string]string, len(b.Settings))
+	for key, value := range b.Settings {
+		if !strings.HasPrefix(value, bundleSecretPrefix) {
+			values[key] = value
+			continue
+		}
+
+		if aead == nil {
+			if passphrase == "" {
+				return nil, ErrBundlePassphraseRequired
+			}
+			salt, err := base64.StdEncoding.DecodeString(b.Salt)
+			if err != nil || len(salt) == 0 {
+				return nil, ErrInvalidBundle
+			}
+			if aead, err = bundleAEAD(passphrase, salt); err != nil {
+				return nil, err
+			}
+		}
+		plaintext, err := openBundleValue(aead, value)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = plaintext
+	}
+	return values, nil
+}
+
+// bundleAEAD 使用 argon2id 由口令派生导出包的加密密钥
+func bundleAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, 3, 64*1024, 2, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealBundleValue(aead cipher.AEAD, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return bundleSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openBundleValue(aead cipher.AEAD, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, bundleSecretPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidBundle
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrBundlePassphraseIncorrect
+	}
+	return string(plaintext), nil
+}
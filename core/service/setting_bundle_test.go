@@ -0,0 +1,261 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gpanel/global"
+)
+
+func TestSettingBundleRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		secrets    string
+		passphrase string
+	}{
+		{"yaml without secrets", "yaml", BundleSecretsExclude, ""},
+		{"json without secrets", "json", BundleSecretsExclude, ""},
+		{"yaml with encrypted secrets", "yaml", BundleSecretsEncrypt, "bundle-pass"},
+		{"json with encrypted secrets", "json", BundleSecretsEncrypt, "bundle-pass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			settings := NewSettingService()
+			bundles := NewSettingBundleService()
+			actor := SettingActor{Username: "admin"}
+			if _, err := settings.UpdateSettings(map[string]string{"LoginMaxAttempts": "6", "SMTPPassword": "smtp-secret"}, actor); err != nil {
+				t.Fatal(err)
+			}
+
+			bundle, err := bundles.Export(tt.secrets, tt.passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := EncodeSettingBundle(bundle, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "smtp-secret") {
+				t.Fatal("secret exported in plaintext")
+			}
+			decoded, err := DecodeSettingBundle(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			values, err := decoded.Values(tt.passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if values["LoginMaxAttempts"] != "6" {
+				t.Fatalf("LoginMaxAttempts = %q, want 6", values["LoginMaxAttempts"])
+			}
+			if _, ok := values["PanelPassword"]; ok {
+				t.Fatal("credentials must never be exported")
+			}
+			secret, ok := values["SMTPPassword"]
+			if tt.secrets == BundleSecretsExclude {
+				if ok {
+					t.Fatal("secret exported without encryption")
+				}
+				return
+			}
+			if secret != "smtp-secret" {
+				t.Fatalf("SMTPPassword = %q, want the original secret", secret)
+			}
+
+			// 修改后导入，应恢复导出时的值
+			if _, err := settings.UpdateSettings(map[string]string{"LoginMaxAttempts": "8", "SMTPPassword": "changed"}, actor); err != nil {
+				t.Fatal(err)
+			}
+			result, err := bundles.Import(values, decoded.Custom, false, actor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Changed) != 2 {
+				t.Fatalf("changed = %v, want LoginMaxAttempts and SMTPPassword", result.Changed)
+			}
+			for _, diff := range result.Changes {
+				if diff.Key == "SMTPPassword" && (strings.Contains(diff.Before, "changed") || strings.Contains(diff.After, "smtp-secret")) {
+					t.Fatalf("secret diff is not masked: %+v", diff)
+				}
+			}
+			restored, err := NewSettingBundleService().Export(BundleSecretsEncrypt, tt.passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := restored.Values(tt.passphrase)
+			if err != nil || again["SMTPPassword"] != "smtp-secret" || again["LoginMaxAttempts"] != "6" {
+				t.Fatalf("imported values = %v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestSettingBundleCreatesCustomSettings(t *testing.T) {
+	setupTestDB(t)
+	actor := SettingActor{Username: "admin"}
+	if err := NewSettingService().CreateSetting("CustomNote", "hello", "shared note", false, actor); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := NewSettingBundleService().Export(BundleSecretsExclude, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncodeSettingBundle(bundle, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 导入到全新的服务器
+	setupTestDB(t)
+	decoded, err := DecodeSettingBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := decoded.Values("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundles := NewSettingBundleService()
+
+	// 未在导出包中声明为自定义设置项的未知设置项仍然拒绝
+	if _, err := bundles.Import(values, nil, true, actor); err == nil {
+		t.Fatal("import accepted an unknown setting")
+	}
+
+	preview, err := bundles.Import(values, decoded.Custom, true, actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := false
+	for _, diff := range preview.Changes {
+		added = added || diff.Key == "CustomNote" && diff.Action == SettingDiffAdd && diff.After == "hello"
+	}
+	if !added {
+		t.Fatalf("changes = %+v, want CustomNote to be added", preview.Changes)
+	}
+	if _, err := bundles.Import(values, decoded.Custom, false, actor); err != nil {
+		t.Fatal(err)
+	}
+	setting, err := settingRepo.GetByKey("CustomNote")
+	if err != nil || setting.Value != "hello" || setting.About != "shared note" {
+		t.Fatalf("CustomNote = %+v, %v", setting, err)
+	}
+}
+
+func TestSettingBundleKeepsCustomSecrets(t *testing.T) {
+	setupTestDB(t)
+	actor := SettingActor{Username: "admin"}
+	if err := NewSettingService().CreateSetting("CustomToken", "tok-123", "", true, actor); err != nil {
+		t.Fatal(err)
+	}
+	bundles := NewSettingBundleService()
+
+	excluded, err := bundles.Export(BundleSecretsExclude, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := excluded.Custom["CustomToken"]; ok {
+		t.Fatal("excluded secret listed in the bundle")
+	}
+	bundle, err := bundles.Export(BundleSecretsEncrypt, "bundle-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bundle.Custom["CustomToken"].Secret {
+		t.Fatalf("custom = %+v, want CustomToken marked secret", bundle.Custom)
+	}
+	values, err := bundle.Values("bundle-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 导入到全新的服务器后仍加密保存，差异中不显示明文
+	setupTestDB(t)
+	if changes := BundleSettingChanges(values, bundle.Custom); changes["CustomToken"].After != redactedValue {
+		t.Fatalf("audit changes = %+v, want the secret redacted", changes["CustomToken"])
+	}
+	result, err := bundles.Import(values, bundle.Custom, false, actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, diff := range result.Changes {
+		if diff.Key == "CustomToken" && diff.After != secretMask {
+			t.Fatalf("secret diff is not masked: %+v", diff)
+		}
+	}
+	setting, err := settingRepo.GetByKey("CustomToken")
+	if err != nil || !setting.Secret || setting.Value == "tok-123" {
+		t.Fatalf("CustomToken = %+v, %v, want it stored encrypted", setting, err)
+	}
+	if value, err := global.DecryptSecret(setting.Value); err != nil || value != "tok-123" {
+		t.Fatalf("decrypted CustomToken = %q, %v", value, err)
+	}
+}
+
+func TestSettingBundleErrors(t *testing.T) {
+	setupTestDB(t)
+	if _, err := NewSettingService().UpdateSettings(map[string]string{"SMTPPassword": "smtp-secret"}, SettingActor{}); err != nil {
+		t.Fatal(err)
+	}
+	bundles := NewSettingBundleService()
+
+	if _, err := bundles.Export("plaintext", ""); !errors.Is(err, ErrUnsupportedBundleSecrets) {
+		t.Fatalf("err = %v, want ErrUnsupportedBundleSecrets", err)
+	}
+	if _, err := bundles.Export(BundleSecretsEncrypt, "short"); !errors.Is(err, ErrBundlePassphraseRequired) {
+		t.Fatalf("err = %v, want ErrBundlePassphraseRequired", err)
+	}
+	bundle, err := bundles.Export(BundleSecretsEncrypt, "bundle-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := bundle.Settings["SMTPPassword"]
+
+	tests := []struct {
+		name       string
+		modify     func(b SettingBundle) *SettingBundle
+		passphrase string
+		wantErr    error
+	}{
+		{"wrong passphrase", nil, "wrong-pass", ErrBundlePassphraseIncorrect},
+		{"missing passphrase", nil, "", ErrBundlePassphraseRequired},
+		{"tampered ciphertext", func(b SettingBundle) *SettingBundle {
+			b.Settings = map[string]string{"SMTPPassword": sealed[:len(sealed)-4] + "AAA="}
+			return &b
+		}, "bundle-pass", ErrBundlePassphraseIncorrect},
+		{"truncated ciphertext", func(b SettingBundle) *SettingBundle {
+			b.Settings = map[string]string{"SMTPPassword": bundleSecretPrefix + "AAAA"}
+			return &b
+		}, "bundle-pass", ErrInvalidBundle},
+		{"missing salt", func(b SettingBundle) *SettingBundle {
+			b.Salt = ""
+			return &b
+		}, "bundle-pass", ErrInvalidBundle},
+		{"other salt", func(b SettingBundle) *SettingBundle {
+			b.Salt = "c2FsdHNhbHRzYWx0c2FsdA=="
+			return &b
+		}, "bundle-pass", ErrBundlePassphraseIncorrect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bundle
+			if tt.modify != nil {
+				b = tt.modify(*bundle)
+			}
+			if _, err := b.Values(tt.passphrase); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	for _, data := range []string{"version: 2\nsettings: {}\n", "version: 1\n", "{not yaml"} {
+		if _, err := DecodeSettingBundle([]byte(data)); !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("DecodeSettingBundle(%q) = %v, want ErrInvalidBundle", data, err)
+		}
+	}
+}
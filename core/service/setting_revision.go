@@ -0,0 +1,263 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/repo"
+)
+
+// ErrNothingToRollback 指定的修订不存在
+var ErrNothingToRollback = errors.New("revision not found")
+
+// SettingActor 修改设置的用户，记录在修订历史中
+type SettingActor struct {
+	UserID   uint
+	Username string
+}
+
+type SettingRevisionService struct{}
+
+type ISettingRevisionService interface {
+	List(filter repo.SettingRevisionFilter, page, pageSize int) ([]models.SettingRevision, int64, error)
+	RollbackTargets(revisionID uint, batch string) ([]models.SettingRevision, error)
+	RestoreTargets(asOf uint) ([]models.SettingRevision, error)
+	Rollback(targets []models.SettingRevision, actor SettingActor) ([]string, error)
+}
+
+func NewSettingRevisionService() ISettingRevisionService {
+	return &SettingRevisionService{}
+}
+
+var settingRevisionRepo repo.ISettingRevisionRepo = repo.NewSettingRevisionRepo()
+
+// settingWrite 一次提交中对单个设置项的修改，stored 写入数据库，cached 写入配置缓存
+type settingWrite struct {
+	key        string
+	stored     string
+	cached     string
+	about      string
+	secret     bool
+	deleted    bool
+	old        *models.Setting
+	rollbackOf uint
+}
+
+// commitSettingWrites 在同一事务中写入设置项和修订记录，提交成功后一次性更新配置缓存
+func commitSettingWrites(writes []settingWrite, actor SettingActor) error {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	batch := hex.EncodeToString(b)
+
+	var settings []models.Setting
+	var deleted []string
+	revisions := make([]models.SettingRevision, 0, len(writes))
+	cached := make(map[string]string)
+	secrets := make(map[string]bool)
+	for _, w := range writes {
+		revision := models.SettingRevision{
+			Batch:      batch,
+			Key:        w.key,
+			NewValue:   w.stored,
+			Secret:     w.secret,
+			Created:    w.old == nil,
+			Deleted:    w.deleted,
+			UserID:     actor.UserID,
+			Username:   actor.Username,
+			RollbackOf: w.rollbackOf,
+		}
+		if w.old != nil {
+			revision.OldValue = w.old.Value
+		}
+		if w.deleted {
+			revision.NewValue = ""
+			deleted = append(deleted, w.key)
+		} else {
+			settings = append(settings, models.Setting{Key: w.key, Value: w.stored, About: w.about, Secret: w.secret})
+			cached[w.key] = w.cached
+			if w.secret {
+				secrets[w.key] = true
+			}
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := settingRepo.SaveBatch(settings, deleted, revisions); err != nil {
+		return err
+	}
+	if global.ConfigCacheInstance != nil {
+		global.ConfigCacheInstance.SetBatch(cached, secrets, deleted)
+		global.NotifyConfigChanged()
+	}
+	return nil
+}
+
+// PublicSettingRevisions 将敏感设置修订中的值替换为掩码
+func PublicSettingRevisions(revisions []models.SettingRevision) []models.SettingRevision {
+	result := make([]models.SettingRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		if revision.Secret || IsSensitiveKey(revision.Key) {
+			revision.Secret = true
+			revision.OldValue = maskSecret(revision.OldValue)
+			revision.NewValue = maskSecret(revision.NewValue)
+		}
+		result = append(result, revision)
+	}
+	return result
+}
+
+// List 分页查询，page 从 1 开始
+func (s *SettingRevisionService) List(filter repo.SettingRevisionFilter, page, pageSize int) ([]models.SettingRevision, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	return settingRevisionRepo.List(filter, (page-1)*pageSize, pageSize)
+}
+
+// RollbackTargets 返回需要回滚的修订：指定 revisionID 时回滚单条修订，指定 batch 时回滚该批次的全部修订
+func (s *SettingRevisionService) RollbackTargets(revisionID uint, batch string) ([]models.SettingRevision, error) {
+	if revisionID != 0 {
+		revision, err := settingRevisionRepo.GetByID(revisionID)
+		if err != nil {
+			return nil, ErrNothingToRollback
+		}
+		return []models.SettingRevision{*revision}, nil
+	}
+
+	revisions, err := settingRevisionRepo.ListByBatch(batch)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, ErrNothingToRollback
+	}
+	return revisions, nil
+}
+
+// RestoreTargets 返回将所有设置项恢复到 asOf 修订提交后状态所需回滚的修订：
+// 每个设置项在 asOf 之后的第一条修订，回滚它即得到该设置项在 asOf 时的值
+func (s *SettingRevisionService) RestoreTargets(asOf uint) ([]models.SettingRevision, error) {
+	if _, err := settingRevisionRepo.GetByID(asOf); err != nil {
+		return nil, ErrNothingToRollback
+	}
+
+	revisions, err := settingRevisionRepo.ListAfter(asOf)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]models.SettingRevision, 0, len(revisions))
+	seen := make(map[string]bool)
+	for _, revision := range revisions {
+		if seen[revision.Key] {
+			continue
+		}
+		seen[revision.Key] = true
+		targets = append(targets, revision)
+	}
+	return targets, nil
+}
+
+// RollbackUpdates 回滚后内置设置项的值，用于回滚前按设置项定义和访问限制校验；
+// 自定义设置项没有取值规则，敏感设置保存的是密文，均不参与校验
+func RollbackUpdates(targets []models.SettingRevision) map[string]string {
+	updates := make(map[string]string, len(targets))
+	for key, value := range RollbackValues(targets) {
+		if _, ok := LookupSettingSchema(key); ok {
+			updates[key] = value
+		}
+	}
+	return updates
+}
+
+// RollbackValues 回滚后非敏感设置项的值，删除的设置项为空，用于回滚前的访问限制校验和审计
+func RollbackValues(targets []models.SettingRevision) map[string]string {
+	values := make(map[string]string, len(targets))
+	for _, revision := range targets {
+		if revision.Secret {
+			continue
+		}
+		if revision.Created {
+			values[revision.Key] = ""
+			continue
+		}
+		values[revision.Key] = revision.OldValue
+	}
+	return values
+}
+
+// RollbackSecuritySettings 返回回滚后值会发生变化的安全设置项，判断方式与 Rollback 一致
+func RollbackSecuritySettings(targets []models.SettingRevision) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, revision := range targets {
+		if seen[revision.Key] || !IsSecuritySetting(revision.Key) {
+			continue
+		}
+		seen[revision.Key] = true
+
+		current, err := settingRepo.GetByKey(revision.Key)
+		if err != nil {
+			current = nil
+		}
+		if (revision.Created && current == nil) || (!revision.Created && current != nil && current.Value == revision.OldValue) {
+			continue
+		}
+		keys = append(keys, revision.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Rollback 将设置项恢复为修订之前的值：修订前不存在的设置项会被删除，被删除的设置项会重新创建。
+// 回滚本身作为新的修订记录，返回实际发生变化的设置项
+func (s *SettingRevisionService) Rollback(targets []models.SettingRevision, actor SettingActor) ([]string, error) {
+	writes := make([]settingWrite, 0, len(targets))
+	changed := make([]string, 0, len(targets))
+	seen := make(map[string]bool)
+	for _, revision := range targets {
+		if seen[revision.Key] {
+			continue
+		}
+		seen[revision.Key] = true
+
+		current, err := settingRepo.GetByKey(revision.Key)
+		if err != nil {
+			current = nil
+		}
+		w := settingWrite{key: revision.Key, secret: revision.Secret, old: current, rollbackOf: revision.ID}
+
+		if revision.Created {
+			if current == nil {
+				continue
+			}
+			w.deleted = true
+		} else {
+			if current != nil && current.Value == revision.OldValue {
+				continue
+			}
+			w.stored, w.cached = revision.OldValue, revision.OldValue
+			if revision.Secret && !IsCredentialKey(revision.Key) {
+				if w.cached, err = global.DecryptSecret(revision.OldValue); err != nil {
+					return nil, err
+				}
+			}
+		}
+		writes = append(writes, w)
+		changed = append(changed, revision.Key)
+	}
+
+	if err := commitSettingWrites(writes, actor); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"testing"
+
+	"gpanel/global"
+	"gpanel/repo"
+)
+
+// latestRevisionID 最近一条修订的 ID
+func latestRevisionID(t *testing.T) uint {
+	t.Helper()
+
+	revisions, _, err := settingRevisionRepo.List(repo.SettingRevisionFilter{}, 0, 1)
+	if err != nil || len(revisions) == 0 {
+		t.Fatalf("no revisions: %v", err)
+	}
+	return revisions[0].ID
+}
+
+func TestRestoreSettingsAsOfRevision(t *testing.T) {
+	setupTestDB(t)
+	settings := NewSettingService()
+	revisions := NewSettingRevisionService()
+	actor := SettingActor{UserID: 1, Username: "admin"}
+
+	if _, err := settings.UpdateSettings(map[string]string{"LoginMaxAttempts": "6"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	asOf := latestRevisionID(t)
+
+	if _, err := settings.UpdateSettings(map[string]string{"LoginMaxAttempts": "7", "LoginBackoffBase": "3"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := settings.UpdateSettings(map[string]string{"LoginMaxAttempts": "8"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.CreateSetting("CustomNote", "hello", "", false, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := revisions.RestoreTargets(asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("got %d restore targets, want one per changed key", len(targets))
+	}
+	changed, err := revisions.Rollback(targets, actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 3 {
+		t.Fatalf("changed = %v, want 3 keys", changed)
+	}
+
+	want := map[string]string{"LoginMaxAttempts": "6", "LoginBackoffBase": "1"}
+	for key, value := range want {
+		if got, _ := global.ConfigCacheInstance.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if _, err := settingRepo.GetByKey("CustomNote"); err == nil {
+		t.Error("setting created after the revision should be removed")
+	}
+
+	if _, err := revisions.RestoreTargets(asOf + 1000); err != ErrNothingToRollback {
+		t.Fatalf("err = %v, want ErrNothingToRollback", err)
+	}
+}
+
+func TestRollbackUpdatesOnlyBuiltInKeys(t *testing.T) {
+	setupTestDB(t)
+	settings := NewSettingService()
+	actor := SettingActor{Username: "admin"}
+
+	if _, err := settings.UpdateSettings(map[string]string{"LoginBackoffBase": "2"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	asOf := latestRevisionID(t)
+	if err := settings.CreateSetting("CustomNote", "hello", "", false, actor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := settings.UpdateSettings(map[string]string{"LoginMaxAttempts": "9"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := NewSettingRevisionService().RestoreTargets(asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := RollbackUpdates(targets)
+	if len(updates) != 1 || updates["LoginMaxAttempts"] != "5" {
+		t.Fatalf("updates = %v, want only LoginMaxAttempts=5", updates)
+	}
+}
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpanel/models"
+	"gpanel/utils"
+)
+
+// SettingType 设置项的值类型，数据库中统一以字符串保存
+type SettingType string
+
+const (
+	SettingTypeString SettingType = "string"
+	SettingTypeInt    SettingType = "int"
+	SettingTypeBool   SettingType = "bool"
+	SettingTypeEnum   SettingType = "enum"
+	SettingTypeJSON   SettingType = "json"
+)
+
+// SettingSchema 设置项定义：类型、默认值、取值范围、说明，以及是否为密钥、修改后是否需要重启、是否为安全设置
+type SettingSchema struct {
+	Key     string      `json:"key"`
+	Type    SettingType `json:"type"`
+	Default string      `json:"default"`
+	Min     *int        `json:"min,omitempty"`
+	Max     *int        `json:"max,omitempty"`
+	Enum    []string    `json:"enum,omitempty"`
+	// Format 字符串的格式提示，供前端选择输入控件，如 ip-list、url、email-list
+	Format          string `json:"format,omitempty"`
+	Description     string `json:"description"`
+	Secret          bool   `json:"secret"`
+	RestartRequired bool   `json:"restartRequired"`
+	// Security 认证和访问控制相关的设置项，修改需要 security:manage 权限
+	Security bool `json:"security"`
+
+	validate func(value string) error
+}
+
+var (
+	ErrUnknownSetting  = errors.New("unknown setting")
+	ErrBuiltInSetting  = errors.New("built-in settings cannot be deleted")
+	ErrSettingNotFound = errors.New("setting not found")
+)
+
+// SettingValidationError 按设置项返回的校验错误
+type SettingValidationError struct {
+	Fields map[string]string
+}
+
+func (e *SettingValidationError) Error() string {
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+": "+e.Fields[key])
+	}
+	return "invalid settings: " + strings.Join(parts, "; ")
+}
+
+func intRange(min, max int) (*int, *int) {
+	return &min, &max
+}
+
+func intSetting(key, def, description string, min, max int) SettingSchema {
+	s := SettingSchema{Key: key, Type: SettingTypeInt, Default: def, Description: description}
+	s.Min, s.Max = intRange(min, max)
+	return s
+}
+
+func boolSetting(key, def, description string) SettingSchema {
+	return SettingSchema{Key: key, Type: SettingTypeBool, Default: def, Description: description}
+}
+
+func enumSetting(key, def, description string, values ...string) SettingSchema {
+	return SettingSchema{Key: key, Type: SettingTypeEnum, Default: def, Enum: values, Description: description}
+}
+
+func stringSetting(key, def, description string) SettingSchema {
+	return SettingSchema{Key: key, Type: SettingTypeString, Default: def, Description: description}
+}
+
+func secretSetting(key, description string) SettingSchema {
+	return SettingSchema{Key: key, Type: SettingTypeString, Description: description, Secret: true}
+}
+
+func (s SettingSchema) restart() SettingSchema {
+	s.RestartRequired = true
+	return s
+}
+
+func (s SettingSchema) security() SettingSchema {
+	s.Security = true
+	return s
+}
+
+func (s SettingSchema) format(format string, validate func(string) error) SettingSchema {
+	s.Format = format
+	s.validate = validate
+	return s
+}
+
+func (s SettingSchema) check(validate func(string) error) SettingSchema {
+	s.validate = validate
+	return s
+}
+
+// roleChoices 外部认证默认角色可选值，空表示拒绝登录
+var roleChoices = []string{"", models.RoleAdmin, models.RoleOperator, models.RoleReadOnly}
+
+// settingSchemas 所有内置设置项，按界面分组顺序排列
+var settingSchemas = []SettingSchema{
+	intSetting("ServerPort", "8080", "服务器端口", 1, 65535).restart(),
+	enumSetting("ServerMode", "debug", "服务器运行模式", "debug", "release", "test").restart(),
+	stringSetting("SecurityEntrance", "", "安全入口路径，/ 表示不启用").format("path", validateEntrancePath).security(),
+	boolSetting("Initialized", "true", "系统是否已初始化"),
+	enumSetting("Language", "zh-CN", "系统语言", "zh-CN", "en-US"),
+	stringSetting("Timezone", "Asia/Shanghai", "时区设置").format("timezone", validateTimezone),
+	intSetting("SessionTimeout", "86400", "会话超时时间（秒）", 60, 30*86400).security(),
+	enumSetting("AuthMode", "token", "登录方式：token（访问 token 由前端保存）或 cookie（访问 token 保存在 HttpOnly cookie 中，并校验 CSRF token）", "token", "cookie").security(),
+	intSetting("AccessTokenTTL", "900", "访问 token 有效期（秒）", 60, 86400).security(),
+	intSetting("SessionIdleTimeout", "7200", "会话空闲超时（秒），超过该时间未刷新需重新登录，0 表示不限制", 0, 30*86400).security(),
+	stringSetting("ServerAddress", "", "服务器地址，开启域名绑定时可填写多个域名，支持 *.example.com").format("domain-list", nil).security(),
+	boolSetting("BindDomain", "false", "是否只允许通过 ServerAddress 中的域名访问面板").security(),
+	stringSetting("ListenAddress", "0.0.0.0", "监听地址").format("ip", validateIP).restart().security(),
+	boolSetting("PasswordComplexityCheck", "false", "密码复杂度验证").security(),
+	intSetting("PasswordMinLength", "8", "密码最小长度", 6, 128).security(),
+	intSetting("PasswordMaxAge", "0", "密码最长使用天数，0 表示不过期", 0, 3650).security(),
+	intSetting("AuditRetentionDays", "90", "审计日志保留天数，0 表示永久保留", 0, 3650),
+
+	boolSetting("LDAPEnabled", "false", "是否启用 LDAP 登录，已存在同名本地账户的用户名不会使用 LDAP 认证").security(),
+	stringSetting("LDAPURL", "", "LDAP 服务器地址，如 ldap://ldap.example.com:389 或 ldaps://ldap.example.com:636").format("url", urlValidator("ldap", "ldaps")).security(),
+	boolSetting("LDAPStartTLS", "false", "LDAP 连接是否使用 StartTLS").security(),
+	boolSetting("LDAPInsecureSkipVerify", "false", "是否跳过 LDAP 服务器证书校验").security(),
+	stringSetting("LDAPBindDN", "", "用于搜索用户的 LDAP 账户 DN，为空表示匿名搜索").security(),
+	secretSetting("LDAPBindPassword", "用于搜索用户的 LDAP 账户密码").security(),
+	stringSetting("LDAPBaseDN", "", "搜索用户的 Base DN").security(),
+	stringSetting("LDAPUserFilter", "(uid=%s)", "搜索用户的过滤器，%s 替换为用户名，Active Directory 可使用 (sAMAccountName=%s)").check(validateLDAPFilter).security(),
+	stringSetting("LDAPGroupAttribute", "memberOf", "用户所属组的属性名").security(),
+	SettingSchema{Key: "LDAPRoleMapping", Type: SettingTypeJSON, Default: "{}", Description: "LDAP 组 DN 到面板角色的映射（JSON），如 {\"cn=admins,ou=groups,dc=example,dc=com\": \"admin\"}", validate: validateRoleMapping}.security(),
+	enumSetting("LDAPDefaultRole", "", "未匹配任何组时的角色，为空表示拒绝登录", roleChoices...).security(),
+	intSetting("LDAPTimeout", "5", "LDAP 连接超时（秒）", 1, 60).security(),
+
+	boolSetting("OIDCEnabled", "false", "是否启用 OpenID Connect 单点登录").security(),
+	stringSetting("OIDCProviderName", "SSO", "登录页显示的身份提供商名称").security(),
+	stringSetting("OIDCIssuer", "", "OIDC Issuer 地址，用于自动发现配置").format("url", urlValidator("http", "https")).security(),
+	stringSetting("OIDCClientID", "", "OIDC Client ID").security(),
+	secretSetting("OIDCClientSecret", "OIDC Client Secret，公共客户端可留空").security(),
+	stringSetting("OIDCRedirectURL", "", "OIDC 回调地址，启用单点登录时必须设置且域名须与访问面板的地址一致，如 https://panel.example.com/api/v1/auth/oidc/callback").format("url", urlValidator("http", "https")).security(),
+	stringSetting("OIDCScopes", "openid profile email", "OIDC 请求的 scope").check(validateOIDCScopes).security(),
+	stringSetting("OIDCUsernameClaim", "preferred_username", "首次登录创建账户时作为用户名的 ID Token 声明，之后按 iss 和 sub 识别用户").check(validateNotEmpty).security(),
+	stringSetting("OIDCRoleClaim", "groups", "用于映射角色的 ID Token 声明").security(),
+	SettingSchema{Key: "OIDCRoleMapping", Type: SettingTypeJSON, Default: "{}", Description: "声明值到面板角色的映射（JSON），如 {\"panel-admins\": \"admin\"}", validate: validateRoleMapping}.security(),
+	enumSetting("OIDCDefaultRole", "", "未匹配任何声明值时的角色，为空表示拒绝登录", roleChoices...).security(),
+
+	boolSetting("TwoFactorRequired", "false", "强制双因素认证").security(),
+	intSetting("LoginMaxAttempts", "5", "锁定前允许的连续登录失败次数", 1, 100).security(),
+	intSetting("LoginLockoutDuration", "900", "登录锁定时长（秒）", 0, 86400).security(),
+	intSetting("LoginBackoffBase", "1", "登录失败等待时间基数（秒）", 0, 3600).security(),
+	intSetting("LoginAttemptWindow", "3600", "登录失败计数窗口（秒），0 表示不重新计数", 0, 7*86400).security(),
+	enumSetting("LoginNotify", "new", "登录通知：new（新位置或新设备登录，位置按 IPv4 /24、IPv6 /48 网段判断）、all（所有成功登录）、off（关闭）", "new", "all", "off"),
+	intSetting("LoginHistoryRetentionDays", "90", "登录记录保留天数，0 表示永久保留", 0, 3650),
+	stringSetting("NotifyWebhookURL", "", "接收通知的 Webhook 地址，以 JSON 格式 POST").format("url", urlValidator("http", "https")),
+	stringSetting("NotifyEmail", "", "接收通知的邮箱，多个用逗号分隔").format("email-list", validateEmailList),
+	stringSetting("SMTPHost", "", "SMTP 服务器地址"),
+	intSetting("SMTPPort", "587", "SMTP 端口，465 使用 TLS，其他端口在服务器支持时使用 STARTTLS", 1, 65535),
+	stringSetting("SMTPUsername", "", "SMTP 用户名"),
+	secretSetting("SMTPPassword", "SMTP 密码"),
+	stringSetting("SMTPFrom", "", "发件人地址").format("email", validateEmail),
+
+	stringSetting("IPAllowList", "", "允许访问面板的 IP/CIDR 列表，为空表示不限制").format("ip-list", validateIPList).security(),
+	stringSetting("IPDenyList", "", "禁止访问面板的 IP/CIDR 列表").format("ip-list", validateIPList).security(),
+	stringSetting("TrustedProxies", "127.0.0.1,::1", "受信任的反向代理地址").format("ip-list", validateIPList).restart().security(),
+	intSetting("EntranceSessionTimeout", "1800", "安全入口 sessionkey 有效期（秒）", 60, 7*86400).security(),
+	boolSetting("EntranceBindIP", "false", "安全入口 sessionkey 是否绑定客户端 IP").security(),
+	boolSetting("EntranceBindUserAgent", "false", "安全入口 sessionkey 是否绑定 User-Agent").security(),
+	boolSetting("EntranceProtectAPI", "false", "API 路由是否也需要经过安全入口").security(),
+	stringSetting("PublicHealthEndpoint", "", "开启 API 保护后仍公开的健康检查路径，如 /api/v1/health").format("path", validateAPIPath).security(),
+	enumSetting("UnauthResponseMode", "page", "未通过安全入口、域名或 IP 校验时的响应：page（提示页面）、404、nginx404、custom（自定义页面）、drop（断开连接）", "page", "404", "nginx404", "custom", "drop"),
+	stringSetting("UnauthCustomPage", "", "响应方式为 custom 时返回的 HTML").format("html", nil),
+}
+
+var settingSchemaIndex = func() map[string]*SettingSchema {
+	index := make(map[string]*SettingSchema, len(settingSchemas))
+	for i := range settingSchemas {
+		index[settingSchemas[i].Key] = &settingSchemas[i]
+	}
+	return index
+}()
+
+// SettingSchemas 返回所有内置设置项定义
+func SettingSchemas() []SettingSchema {
+	return settingSchemas
+}
+
+// LookupSettingSchema 查找设置项定义，自定义设置项返回 false
+func LookupSettingSchema(key string) (*SettingSchema, bool) {
+	schema, ok := settingSchemaIndex[key]
+	return schema, ok
+}
+
+// Validate 按类型、范围和格式校验值
+func (s *SettingSchema) Validate(value string) error {
+	switch s.Type {
+	case SettingTypeInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		if (s.Min != nil && n < *s.Min) || (s.Max != nil && n > *s.Max) {
+			return fmt.Errorf("must be between %d and %d", *s.Min, *s.Max)
+		}
+	case SettingTypeBool:
+		if value != "true" && value != "false" {
+			return errors.New("must be true or false")
+		}
+	case SettingTypeEnum:
+		if !slices.Contains(s.Enum, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+	case SettingTypeJSON:
+		if !json.Valid([]byte(value)) {
+			return errors.New("must be valid JSON")
+		}
+	}
+
+	if s.validate != nil {
+		return s.validate(value)
+	}
+	return nil
+}
+
+// RestartRequired 修改的设置项中是否有需要重启才能生效的
+func RestartRequired(keys []string) bool {
+	for _, key := range keys {
+		if schema, ok := settingSchemaIndex[key]; ok && schema.RestartRequired {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSecuritySetting 判断设置项是否为凭据或认证、访问控制相关的安全设置
+func IsSecuritySetting(key string) bool {
+	if IsCredentialKey(key) {
+		return true
+	}
+	schema, ok := settingSchemaIndex[key]
+	return ok && schema.Security
+}
+
+var entrancePathPattern = regexp.MustCompile(`^/?[A-Za-z0-9_\-/]{0,64}$`)
+
+// reservedEntrancePrefixes 安全入口中间件直接放行的前缀，以此开头的入口永远无法签发 sessionkey
+var reservedEntrancePrefixes = []string{"/api", "/assets"}
+
+// reservedEntranceRoutes 前端页面路由，用作入口会与页面冲突
+var reservedEntranceRoutes = []string{"/login", "/dashboard", "/settings"}
+
+func validateEntrancePath(value string) error {
+	if value == "" || !entrancePathPattern.MatchString(value) {
+		return errors.New("must be a path of letters, digits, '-', '_' or '/'")
+	}
+
+	path := "/" + strings.TrimPrefix(value, "/")
+	for _, prefix := range reservedEntrancePrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return fmt.Errorf("must not start with reserved prefix %s", prefix)
+		}
+	}
+	for _, route := range reservedEntranceRoutes {
+		if path == route || strings.HasPrefix(path, route+"/") {
+			return fmt.Errorf("must not use the panel route %s", route)
+		}
+	}
+	return nil
+}
+
+func validateAPIPath(value string) error {
+	if value != "" && !strings.HasPrefix(value, "/api/") {
+		return errors.New("must start with /api/")
+	}
+	return nil
+}
+
+func validateTimezone(value string) error {
+	if _, err := time.LoadLocation(value); err != nil {
+		return errors.New("unknown timezone")
+	}
+	return nil
+}
+
+func validateIP(value string) error {
+	if net.ParseIP(value) == nil {
+		return errors.New("must be an IP address")
+	}
+	return nil
+}
+
+func validateIPList(value string) error {
+	_, err := utils.ParseIPList(value)
+	return err
+}
+
+func validateNotEmpty(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return errors.New("must not be empty")
+	}
+	return nil
+}
+
+// urlValidator 允许为空，非空时必须为指定协议的 URL
+func urlValidator(schemes ...string) func(string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" || !slices.Contains(schemes, u.Scheme) {
+			return fmt.Errorf("must be a %s URL", strings.Join(schemes, "/"))
+		}
+		return nil
+	}
+}
+
+func validateLDAPFilter(value string) error {
+	if !strings.Contains(value, "%s") {
+		return errors.New("must contain %s as the username placeholder")
+	}
+	return nil
+}
+
+func validateOIDCScopes(value string) error {
+	if !slices.Contains(strings.Fields(value), "openid") {
+		return errors.New("must include openid")
+	}
+	return nil
+}
+
+func validateRoleMapping(value string) error {
+	mapping := make(map[string]string)
+	if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+		return errors.New("must be a JSON object of strings")
+	}
+	for group, role := range mapping {
+		if !models.IsValidRole(role) {
+			return fmt.Errorf("invalid role %q for %q", role, group)
+		}
+	}
+	return nil
+}
+
+func validateEmail(value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(value); err != nil {
+		return errors.New("must be an email address")
+	}
+	return nil
+}
+
+func validateEmailList(value string) error {
+	for _, item := range splitList(value) {
+		if _, err := mail.ParseAddress(item); err != nil {
+			return fmt.Errorf("invalid email address %q", item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateEntrancePath(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"/", false},
+		{"/abc123", false},
+		{"panel-entry", false},
+		{"/ops/entry_1", false},
+		{"/loginx", false},
+		{"", true},
+		{"/a b", true},
+		{"/api", true},
+		{"/api/v1", true},
+		{"/apix", true},
+		{"api", true},
+		{"/assets", true},
+		{"/assets2", true},
+		{"/login", true},
+		{"/dashboard/x", true},
+		{"settings", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if err := validateEntrancePath(tt.value); (err != nil) != tt.wantErr {
+				t.Fatalf("validateEntrancePath(%q) = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+
+	for i := 0; i < 100; i++ {
+		if entrance := generateRandomEntrance(); validateEntrancePath(entrance) != nil {
+			t.Fatalf("generated invalid entrance %q", entrance)
+		}
+	}
+}
+
+func TestDeleteSettingRejectsBuiltInKeys(t *testing.T) {
+	setupTestDB(t)
+	settings := NewSettingService()
+	actor := SettingActor{Username: "admin"}
+
+	if err := settings.DeleteSetting("LoginMaxAttempts", actor); !errors.Is(err, ErrBuiltInSetting) {
+		t.Fatalf("err = %v, want ErrBuiltInSetting", err)
+	}
+	if _, err := settingRepo.GetByKey("LoginMaxAttempts"); err != nil {
+		t.Fatalf("built-in setting was deleted: %v", err)
+	}
+
+	if err := settings.CreateSetting("CustomNote", "hello", "", false, actor); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.DeleteSetting("CustomNote", actor); err != nil {
+		t.Fatalf("delete custom setting: %v", err)
+	}
+	if err := settings.DeleteSetting("CustomNote", actor); !errors.Is(err, ErrSettingNotFound) {
+		t.Fatalf("delete missing setting: err = %v, want ErrSettingNotFound", err)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"gpanel/global"
+	"gpanel/models"
+)
+
+func TestUpdateSettingsRollsBackOnFailure(t *testing.T) {
+	updates := map[string]string{
+		"LoginBackoffBase":   "4",
+		"LoginMaxAttempts":   "9",
+		"SessionIdleTimeout": "600",
+	}
+	tests := []struct {
+		name    string
+		trigger string
+		updates map[string]string
+	}{
+		{
+			"first key fails",
+			`CREATE TRIGGER fail_write BEFORE UPDATE ON settings WHEN NEW.key = 'LoginBackoffBase' BEGIN SELECT RAISE(ABORT, 'write failed'); END`,
+			updates,
+		},
+		{
+			"last key fails",
+			`CREATE TRIGGER fail_write BEFORE UPDATE ON settings WHEN NEW.key = 'SessionIdleTimeout' BEGIN SELECT RAISE(ABORT, 'write failed'); END`,
+			updates,
+		},
+		{
+			"revision insert fails",
+			`CREATE TRIGGER fail_write BEFORE INSERT ON setting_revisions BEGIN SELECT RAISE(ABORT, 'write failed'); END`,
+			updates,
+		},
+		{"invalid value", "", map[string]string{"LoginMaxAttempts": "9", "SessionIdleTimeout": "-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			before, _ := global.ConfigCacheInstance.Get("LoginMaxAttempts")
+			var revisionsBefore int64
+			global.DB.Model(&models.SettingRevision{}).Count(&revisionsBefore)
+			if tt.trigger != "" {
+				if err := global.DB.Exec(tt.trigger).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := NewSettingService().UpdateSettings(tt.updates, SettingActor{Username: "admin"}); err == nil {
+				t.Fatal("update should fail")
+			}
+
+			for key, value := range tt.updates {
+				if setting, err := settingRepo.GetByKey(key); err == nil && setting.Value == value {
+					t.Errorf("%s was written despite the failed batch", key)
+				}
+				if cached, _ := global.ConfigCacheInstance.Get(key); cached == value {
+					t.Errorf("%s was cached despite the failed batch", key)
+				}
+			}
+			if after, _ := global.ConfigCacheInstance.Get("LoginMaxAttempts"); after != before {
+				t.Errorf("LoginMaxAttempts = %q, want %q", after, before)
+			}
+			var revisionsAfter int64
+			global.DB.Model(&models.SettingRevision{}).Count(&revisionsAfter)
+			if revisionsAfter != revisionsBefore {
+				t.Errorf("%d revisions recorded for the failed batch", revisionsAfter-revisionsBefore)
+			}
+		})
+	}
+}
+
+func TestRollbackSettingsIsAtomic(t *testing.T) {
+	setupTestDB(t)
+	settings := NewSettingService()
+	revisions := NewSettingRevisionService()
+	actor := SettingActor{Username: "admin"}
+
+	if _, err := settings.UpdateSettings(map[string]string{"LoginMaxAttempts": "6"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	asOf := latestRevisionID(t)
+	if _, err := settings.UpdateSettings(map[string]string{"LoginMaxAttempts": "7"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.CreateSetting("CustomNote", "hello", "", false, actor); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := revisions.RestoreTargets(asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复时需要删除 CustomNote，删除失败时 LoginMaxAttempts 也不能被恢复
+	if err := global.DB.Exec(`CREATE TRIGGER fail_delete BEFORE DELETE ON settings BEGIN SELECT RAISE(ABORT, 'delete failed'); END`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := revisions.Rollback(targets, actor); err == nil {
+		t.Fatal("rollback should fail")
+	}
+	if setting, err := settingRepo.GetByKey("LoginMaxAttempts"); err != nil || setting.Value != "7" {
+		t.Fatalf("LoginMaxAttempts = %+v, %v, want 7", setting, err)
+	}
+	if value, _ := global.ConfigCacheInstance.Get("LoginMaxAttempts"); value != "7" {
+		t.Fatalf("cached LoginMaxAttempts = %q, want 7", value)
+	}
+	if _, err := settingRepo.GetByKey("CustomNote"); err != nil {
+		t.Fatalf("CustomNote was removed: %v", err)
+	}
+}
+
+func TestUpdateSettingsRejectsOverriddenSettings(t *testing.T) {
+	t.Setenv("GPANEL_SERVER_PORT", "9090")
+	if err := global.LoadBootstrapConfig(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Unsetenv("GPANEL_SERVER_PORT")
+		_ = global.LoadBootstrapConfig()
+	})
+	setupTestDB(t)
+	settings := NewSettingService()
+	actor := SettingActor{Username: "admin"}
+
+	// 修改被环境变量覆盖的设置不会生效，应拒绝
+	_, err := settings.UpdateSettings(map[string]string{"ServerPort": "9191", "Language": "en-US"}, actor)
+	var validationErr *SettingValidationError
+	if !errors.As(err, &validationErr) || validationErr.Fields["ServerPort"] == "" {
+		t.Fatalf("err = %v, want a validation error for ServerPort", err)
+	}
+	if value, _ := global.ConfigCacheInstance.Get("Language"); value == "en-US" {
+		t.Fatal("batch with an overridden setting was partly applied")
+	}
+
+	// 设置页面提交当前生效值时不报错，也不写入数据库
+	before, err := settingRepo.GetByKey("ServerPort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := settings.UpdateSettings(map[string]string{"ServerPort": "9090", "Language": "en-US"}, actor)
+	if err != nil {
+		t.Fatalf("resubmit effective value: %v", err)
+	}
+	if len(changed) != 1 || changed[0] != "Language" {
+		t.Fatalf("changed = %v, want [Language]", changed)
+	}
+	if after, _ := settingRepo.GetByKey("ServerPort"); after.Value != before.Value {
+		t.Fatalf("ServerPort in database = %q, want %q", after.Value, before.Value)
+	}
+}
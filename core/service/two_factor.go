@@ -0,0 +1,288 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/repo"
+	"gpanel/utils"
+)
+
+const (
+	twoFactorIssuer   = "GPanel"
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotSetup       = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// TwoFactorSetup 开启双因素认证时返回给客户端的绑定信息
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+	QRCode     string `json:"qrCode"`
+}
+
+// TwoFactorStatus 双因素认证状态
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recoveryCodesRemaining"`
+}
+
+type TwoFactorService struct{}
+
+type ITwoFactorService interface {
+	IsEnabled(username string) bool
+	GetStatus(username string, required bool) *TwoFactorStatus
+	Setup(username string) (*TwoFactorSetup, error)
+	Enable(username, code string) ([]string, error)
+	Disable(username, code string) error
+	Verify(username, code string) error
+	RegenerateRecoveryCodes(username, code string) ([]string, error)
+	EncryptSecrets() error
+}
+
+func NewTwoFactorService() ITwoFactorService {
+	return &TwoFactorService{}
+}
+
+var twoFactorRepo repo.ITwoFactorRepo = repo.NewTwoFactorRepo()
+
+// twoFactorMu 串行化验证码校验，避免同一验证码被并发重复使用
+var twoFactorMu sync.Mutex
+
+func (s *TwoFactorService) IsEnabled(username string) bool {
+	twoFactor, err := twoFactorRepo.GetByUsername(username)
+	return err == nil && twoFactor.Enabled
+}
+
+func (s *TwoFactorService) GetStatus(username string, required bool) *TwoFactorStatus {
+	status := &TwoFactorStatus{Required: required}
+	twoFactor, err := twoFactorRepo.GetByUsername(username)
+	if err == nil && twoFactor.Enabled {
+		status.Enabled = true
+		status.RecoveryCodesRemaining = len(decodeRecoveryCodes(twoFactor.RecoveryCodes))
+	}
+	return status
+}
+
+// Setup 生成新的 TOTP 密钥，需调用 Enable 验证后才会生效
+func (s *TwoFactorService) Setup(username string) (*TwoFactorSetup, error) {
+	twoFactor, err := twoFactorRepo.GetByUsername(username)
+	if err != nil {
+		twoFactor = &models.TwoFactor{Username: username}
+	}
+	if twoFactor.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	twoFactor.Secret, err = global.EncryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	twoFactor.LastUsedStep = 0
+	if err := twoFactorRepo.Save(twoFactor); err != nil {
+		return nil, err
+	}
+
+	uri := utils.TOTPAuthURI(twoFactorIssuer, username, secret)
+	qrCode, err := utils.QRCodeDataURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRCode:     qrCode,
+	}, nil
+}
+
+// Enable 校验首个验证码后启用双因素认证，并返回一次性恢复码
+func (s *TwoFactorService) Enable(username, code string) ([]string, error) {
+	twoFactorMu.Lock()
+	defer twoFactorMu.Unlock()
+
+	twoFactor, err := twoFactorRepo.GetByUsername(username)
+	if err != nil || twoFactor.Secret == "" {
+		return nil, ErrTwoFactorNotSetup
+	}
+	if twoFactor.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := global.DecryptSecret(twoFactor.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), twoFactor.LastUsedStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor.Enabled = true
+	twoFactor.LastUsedStep = step
+	twoFactor.RecoveryCodes = hashes
+	if err := twoFactorRepo.Save(twoFactor); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *TwoFactorService) Disable(username, code string) error {
+	if err := s.Verify(username, code); err != nil {
+		return err
+	}
+	return twoFactorRepo.Delete(username)
+}
+
+// Verify 校验 TOTP 验证码或一次性恢复码
+func (s *TwoFactorService) Verify(username, code string) error {
+	twoFactorMu.Lock()
+	defer twoFactorMu.Unlock()
+
+	twoFactor, err := twoFactorRepo.GetByUsername(username)
+	if err != nil || !twoFactor.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	secret, err := global.DecryptSecret(twoFactor.Secret)
+	if err != nil {
+		return err
+	}
+	if step, ok := utils.ValidateTOTP(secret, code, time.Now(), twoFactor.LastUsedStep); ok {
+		twoFactor.LastUsedStep = step
+		return twoFactorRepo.Save(twoFactor)
+	}
+
+	// 尝试作为恢复码使用，使用后立即作废
+	remaining, ok := consumeRecoveryCode(twoFactor.RecoveryCodes, code)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	twoFactor.RecoveryCodes = remaining
+	return twoFactorRepo.Save(twoFactor)
+}
+
+func (s *TwoFactorService) RegenerateRecoveryCodes(username, code string) ([]string, error) {
+	if err := s.Verify(username, code); err != nil {
+		return nil, err
+	}
+
+	twoFactor, err := twoFactorRepo.GetByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	twoFactor.RecoveryCodes = hashes
+	if err := twoFactorRepo.Save(twoFactor); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// EncryptSecrets 加密旧版本明文保存的 TOTP 密钥，启动时调用
+func (s *TwoFactorService) EncryptSecrets() error {
+	twoFactors, err := twoFactorRepo.List()
+	if err != nil {
+		return err
+	}
+
+	for i := range twoFactors {
+		twoFactor := &twoFactors[i]
+		if twoFactor.Secret == "" || global.IsEncryptedSecret(twoFactor.Secret) {
+			continue
+		}
+		if twoFactor.Secret, err = global.EncryptSecret(twoFactor.Secret); err != nil {
+			return err
+		}
+		if err := twoFactorRepo.Save(twoFactor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateRecoveryCodes 生成恢复码，返回明文列表及用于存储的哈希 JSON
+func generateRecoveryCodes() ([]string, string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, "", err
+	}
+	return codes, string(data), nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func decodeRecoveryCodes(data string) []string {
+	var hashes []string
+	if data == "" {
+		return hashes
+	}
+	_ = json.Unmarshal([]byte(data), &hashes)
+	return hashes
+}
+
+// consumeRecoveryCode 匹配并移除恢复码，返回剩余恢复码的 JSON
+func consumeRecoveryCode(data, code string) (string, bool) {
+	hashes := decodeRecoveryCodes(data)
+	candidate := hashRecoveryCode(code)
+
+	for i, hash := range hashes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(candidate)) == 1 {
+			remaining := append(hashes[:i:i], hashes[i+1:]...)
+			encoded, err := json.Marshal(remaining)
+			if err != nil {
+				return data, false
+			}
+			return string(encoded), true
+		}
+	}
+	return data, false
+}
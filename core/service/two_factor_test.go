@@ -0,0 +1,149 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gpanel/global"
+	"gpanel/models"
+	"gpanel/utils"
+)
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	code, err := utils.TOTPCode(secret, time.Now().Unix()/30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTwoFactorSecretEncrypted(t *testing.T) {
+	setupTestDB(t)
+	twoFactors := NewTwoFactorService()
+
+	setup, err := twoFactors.Setup("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := twoFactorRepo.GetByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !global.IsEncryptedSecret(stored.Secret) || stored.Secret == setup.Secret {
+		t.Fatalf("secret stored in plaintext: %q", stored.Secret)
+	}
+
+	if _, err := twoFactors.Enable("alice", currentTOTPCode(t, setup.Secret)); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+}
+
+func TestTwoFactorEncryptLegacySecrets(t *testing.T) {
+	setupTestDB(t)
+	twoFactors := NewTwoFactorService()
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := twoFactorRepo.Save(&models.TwoFactor{Username: "bob", Secret: secret, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := twoFactors.EncryptSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := twoFactorRepo.GetByUsername("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !global.IsEncryptedSecret(stored.Secret) {
+		t.Fatalf("legacy secret was not encrypted: %q", stored.Secret)
+	}
+
+	// 再次执行不应重复加密
+	if err := twoFactors.EncryptSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := twoFactorRepo.GetByUsername("bob")
+	if again.Secret != stored.Secret {
+		t.Fatal("encrypted secret was encrypted again")
+	}
+
+	if err := twoFactors.Verify("bob", currentTOTPCode(t, secret)); err != nil {
+		t.Fatalf("verify after migration: %v", err)
+	}
+}
+
+func TestTwoFactorVerifyRejectsReplay(t *testing.T) {
+	setupTestDB(t)
+	twoFactors := NewTwoFactorService()
+
+	setup, err := twoFactors.Setup("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := time.Now().Unix() / 30
+	codeAt := func(step int64) string {
+		code, err := utils.TOTPCode(setup.Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	recovery, err := twoFactors.Enable("alice", codeAt(step))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 按顺序执行，前面的校验会影响后面的结果
+	tests := []struct {
+		name    string
+		code    string
+		wantErr bool
+	}{
+		{"code used to enable", codeAt(step), true},
+		{"earlier step", codeAt(step - 1), true},
+		{"next step within skew", codeAt(step + 1), false},
+		{"next step replayed", codeAt(step + 1), true},
+		{"recovery code", recovery[0], false},
+		{"recovery code reused", recovery[0], true},
+		{"garbage", "not-a-code", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := twoFactors.Verify("alice", tt.code)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidTwoFactorCode) {
+				t.Fatalf("err = %v, want ErrInvalidTwoFactorCode", err)
+			}
+		})
+	}
+
+	// 同一恢复码并发使用只能成功一次
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = twoFactors.Verify("alice", recovery[1])
+		}(i)
+	}
+	wg.Wait()
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("recovery code accepted %d times, want once", succeeded)
+	}
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"gpanel/models"
+	"gpanel/repo"
+	"gpanel/utils"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUsernameTaken      = errors.New("username already exists")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrInvalidUsername    = errors.New("invalid username")
+	ErrInvalidPassword    = errors.New("invalid password")
+	ErrLastAdmin          = errors.New("at least one active admin is required")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrExternalUser       = errors.New("password of externally authenticated users cannot be changed")
+)
+
+// UserUpdate 用户更新内容，nil 表示不修改
+type UserUpdate struct {
+	Username *string `json:"username"`
+	Password *string `json:"password"`
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type UserService struct{}
+
+type IUserService interface {
+	List() ([]models.User, error)
+	GetByID(id uint) (*models.User, error)
+	GetByUsername(username string) (*models.User, error)
+	Create(username, password, role string) (*models.User, error)
+	Update(id uint, update UserUpdate) (*models.User, error)
+	Delete(id uint) error
+	Authenticate(username, password string) (*models.User, error)
+	ChangePassword(id uint, currentPassword, newPassword string) (*models.User, error)
+	InitializeDefaultAdmin() error
+}
+
+func NewUserService() IUserService {
+	return &UserService{}
+}
+
+var userRepo repo.IUserRepo = repo.NewUserRepo()
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// getDummyHash 用户不存在时也执行一次哈希校验，避免通过响应时间枚举用户名
+func getDummyHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = utils.HashPassword("gpanel-dummy-password")
+	})
+	return dummyHash
+}
+
+func (s *UserService) List() ([]models.User, error) {
+	return userRepo.List()
+}
+
+func (s *UserService) GetByID(id uint) (*models.User, error) {
+	user, err := userRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) GetByUsername(username string) (*models.User, error) {
+	user, err := userRepo.GetByUsername(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) Create(username, password, role string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, ErrInvalidUsername
+	}
+	if !models.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if _, err := userRepo.GetByUsername(username); err == nil {
+		return nil, ErrUsernameTaken
+	}
+	if err := ValidatePassword(password, username); err != nil {
+		return nil, err
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Username:          username,
+		PasswordHash:      hash,
+		Role:              role,
+		PasswordChangedAt: &now,
+	}
+	if err := userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) Update(id uint, update UserUpdate) (*models.User, error) {
+	user, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	oldUsername := user.Username
+	wasActiveAdmin := user.Role == models.RoleAdmin && !user.Disabled
+
+	if update.Username != nil {
+		username := strings.TrimSpace(*update.Username)
+		if username == "" {
+			return nil, ErrInvalidUsername
+		}
+		if username != user.Username {
+			if _, err := userRepo.GetByUsername(username); err == nil {
+				return nil, ErrUsernameTaken
+			}
+			user.Username = username
+		}
+	}
+	if update.Role != nil {
+		if !models.IsValidRole(*update.Role) {
+			return nil, ErrInvalidRole
+		}
+		user.Role = *update.Role
+	}
+	if update.Disabled != nil {
+		user.Disabled = *update.Disabled
+	}
+	if update.Password != nil {
+		if !user.IsLocal() {
+			return nil, ErrExternalUser
+		}
+		if *update.Password == "" {
+			return nil, ErrInvalidPassword
+		}
+		if err := ValidatePassword(*update.Password, user.Username); err != nil {
+			return nil, err
+		}
+		if err := setPassword(user, *update.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	// 不允许降级或禁用最后一个管理员，检查与保存在同一事务中
+	if wasActiveAdmin && (user.Role != models.RoleAdmin || user.Disabled) {
+		kept, err := userRepo.SaveKeepingAdmin(user)
+		if err != nil {
+			return nil, err
+		}
+		if !kept {
+			return nil, ErrLastAdmin
+		}
+	} else if err := userRepo.Save(user); err != nil {
+		return nil, err
+	}
+
+	// 禁用用户或重置密码后，吊销该用户的所有会话和 API token，重新启用后旧凭据也不会恢复
+	if user.Disabled || update.Password != nil {
+		if err := apiTokenRepo.RevokeByUser(user.ID, time.Now()); err != nil {
+			return nil, err
+		}
+		if err := sessionRepo.RevokeByUser(user.ID, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.Username != oldUsername {
+		if err := twoFactorRepo.Rename(oldUsername, user.Username); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func (s *UserService) Delete(id uint) error {
+	user, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if user.Role == models.RoleAdmin && !user.Disabled {
+		kept, err := userRepo.DeleteKeepingAdmin(id)
+		if err != nil {
+			return err
+		}
+		if !kept {
+			return ErrLastAdmin
+		}
+	} else if err := userRepo.Delete(id); err != nil {
+		return err
+	}
+	if err := apiTokenRepo.RevokeByUser(id, time.Now()); err != nil {
+		return err
+	}
+	if err := sessionRepo.RevokeByUser(id, time.Now()); err != nil {
+		return err
+	}
+	return twoFactorRepo.Delete(user.Username)
+}
+
+// Authenticate 校验用户名和密码，密码哈希参数过时时自动重新计算
+func (s *UserService) Authenticate(username, password string) (*models.User, error) {
+	user, err := userRepo.GetByUsername(username)
+	if err != nil || !user.IsLocal() {
+		_, _, _ = utils.VerifyPassword(password, getDummyHash())
+		return nil, ErrInvalidCredentials
+	}
+
+	match, needsRehash, err := utils.VerifyPassword(password, user.PasswordHash)
+	if err != nil {
+		log.Printf("Failed to verify password for user %s: %v", user.Username, err)
+	}
+	if !match || user.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+
+	// 仍在使用默认密码，要求登录后先修改密码
+	if password == defaultAdminPassword && !user.MustChangePassword {
+		user.MustChangePassword = true
+		if err := userRepo.Save(user); err != nil {
+			log.Printf("Failed to flag default password for user %s: %v", user.Username, err)
+		}
+	}
+
+	if needsRehash {
+		if hash, err := utils.HashPassword(password); err == nil {
+			user.PasswordHash = hash
+			if err := userRepo.Save(user); err != nil {
+				log.Printf("Failed to rehash password for user %s: %v", user.Username, err)
+			}
+		}
+	}
+	return user, nil
+}
+
+// ChangePassword 用户修改自己的密码，需要校验当前密码。修改后吊销该用户的所有会话
+func (s *UserService) ChangePassword(id uint, currentPassword, newPassword string) (*models.User, error) {
+	user, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsLocal() {
+		return nil, ErrExternalUser
+	}
+
+	match, _, err := utils.VerifyPassword(currentPassword, user.PasswordHash)
+	if err != nil || !match {
+		return nil, ErrIncorrectPassword
+	}
+	if newPassword == currentPassword {
+		return nil, ErrPasswordReused
+	}
+	if err := ValidatePassword(newPassword, user.Username); err != nil {
+		return nil, err
+	}
+
+	if err := setPassword(user, newPassword); err != nil {
+		return nil, err
+	}
+	if err := userRepo.Save(user); err != nil {
+		return nil, err
+	}
+	if err := sessionRepo.RevokeByUser(user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// setPassword 更新密码哈希并清除强制修改密码标记
+func setPassword(user *models.User, password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.PasswordHash = hash
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = false
+	return nil
+}
+
+// InitializeDefaultAdmin 没有任何用户时，将旧版本 PanelUser/PanelPassword 设置迁移为管理员账户
+func (s *UserService) InitializeDefaultAdmin() error {
+	count, err := userRepo.Count()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	username := "admin"
+	if value, err := settingRepo.GetValueByKey("PanelUser"); err == nil && value != "" {
+		username = value
+	}
+
+	// 旧版本可能是明文密码，也可能已经是哈希
+	passwordHash := ""
+	mustChangePassword := false
+	if value, err := settingRepo.GetValueByKey("PanelPassword"); err == nil && value != "" {
+		if utils.IsPasswordHash(value) {
+			passwordHash = value
+		} else if passwordHash, err = utils.HashPassword(value); err != nil {
+			return err
+		}
+	}
+	if passwordHash == "" {
+		if passwordHash, err = utils.HashPassword(defaultAdminPassword); err != nil {
+			return err
+		}
+		mustChangePassword = true
+	}
+
+	if err := userRepo.Create(&models.User{
+		Username:           username,
+		PasswordHash:       passwordHash,
+		Role:               models.RoleAdmin,
+		MustChangePassword: mustChangePassword,
+	}); err != nil {
+		return err
+	}
+
+	_ = settingRepo.Delete("PanelUser")
+	_ = settingRepo.Delete("PanelPassword")
+	log.Printf("Migrated panel credentials to admin user: %s", username)
+	return nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"gpanel/models"
+	"gpanel/utils"
+)
+
+func TestUserDisableAndResetRevokeAPITokens(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserService()
+	tokens := NewAPITokenService()
+
+	if _, err := users.Create("admin", "Admin-Pass-123", models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	user, err := users.Create("ops", "Ops-Pass-123", models.RoleOperator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled, enabled, password := true, false, "Ops-Pass-456"
+	tests := []struct {
+		name   string
+		update UserUpdate
+	}{
+		{"disable then enable", UserUpdate{Disabled: &disabled}},
+		{"admin password reset", UserUpdate{Password: &password}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _, err := tokens.Create(user, tt.name, []string{models.PermSystemRead}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := users.Update(user.ID, tt.update); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := users.Update(user.ID, UserUpdate{Disabled: &enabled}); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := tokens.Authenticate(raw); err == nil {
+				t.Fatal("api token should stay revoked")
+			}
+		})
+	}
+}
+
+func TestUserLastAdminConcurrentDemotion(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserService()
+
+	var ids []uint
+	for _, name := range []string{"admin1", "admin2"} {
+		user, err := users.Create(name, "Admin-Pass-123", models.RoleAdmin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	// 两个管理员同时降级，只能有一个成功
+	role := models.RoleReadOnly
+	var wg sync.WaitGroup
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id uint) {
+			defer wg.Done()
+			_, errs[i] = users.Update(id, UserUpdate{Role: &role})
+		}(i, id)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrLastAdmin) {
+			failed++
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("%d demotions rejected, want exactly 1", failed)
+	}
+
+	remaining := ids[0]
+	if errs[0] == nil {
+		remaining = ids[1]
+	}
+	if err := users.Delete(remaining); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("deleting the last admin: err = %v, want ErrLastAdmin", err)
+	}
+}
+
+func TestAuthenticateRehashesOutdatedPasswords(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserService()
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("Legacy-Pass-123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := utils.HashPassword("Current-Pass-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		username   string
+		hash       string
+		password   string
+		wantErr    bool
+		wantRehash bool
+	}{
+		{"bcrypt is upgraded", "legacy", string(legacy), "Legacy-Pass-123", false, true},
+		{"wrong password keeps bcrypt", "legacy2", string(legacy), "wrong", true, false},
+		{"current argon2id is kept", "current", current, "Current-Pass-123", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &models.User{Username: tt.username, PasswordHash: tt.hash, Role: models.RoleOperator}
+			if err := userRepo.Create(user); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := users.Authenticate(user.Username, tt.password); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			stored, err := userRepo.GetByUsername(user.Username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rehashed := stored.PasswordHash != tt.hash; rehashed != tt.wantRehash {
+				t.Fatalf("rehashed = %v, want %v", rehashed, tt.wantRehash)
+			}
+			if tt.wantRehash {
+				if !strings.HasPrefix(stored.PasswordHash, "$argon2id$") {
+					t.Fatalf("hash = %q, want argon2id", stored.PasswordHash)
+				}
+				if _, err := users.Authenticate(user.Username, tt.password); err != nil {
+					t.Fatalf("login with upgraded hash: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ParseDomainList 解析逗号、空白或换行分隔的域名列表，条目可以带协议和端口，如 https://panel.example.com:8080
+func ParseDomainList(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+
+	domains := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if strings.Contains(field, "://") {
+			if u, err := url.Parse(field); err == nil {
+				field = u.Host
+			}
+		}
+		if i := strings.Index(field, "/"); i >= 0 {
+			field = field[:i]
+		}
+		if domain := NormalizeHost(field); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// NormalizeHost 去掉 Host 中的端口、IPv6 方括号和末尾的点，并转为小写
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimPrefix(host, "[")
+	host = strings.TrimSuffix(host, "]")
+	host = strings.TrimSuffix(host, ".")
+	return strings.ToLower(host)
+}
+
+// MatchDomain 判断 host 是否匹配列表中的任一域名，*.example.com 匹配 example.com 的所有子域名
+func MatchDomain(domains []string, host string) bool {
+	host = NormalizeHost(host)
+	if host == "" {
+		return false
+	}
+
+	for _, domain := range domains {
+		if suffix, ok := strings.CutPrefix(domain, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == domain {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDomainList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", []string{}},
+		{"Panel.Example.com", []string{"panel.example.com"}},
+		{"https://panel.example.com:8443/login", []string{"panel.example.com"}},
+		{"panel.example.com:8080, *.example.org\n[2001:db8::1]:8080", []string{"panel.example.com", "*.example.org", "2001:db8::1"}},
+		{"example.com.", []string{"example.com"}},
+	}
+	for _, tt := range tests {
+		if got := ParseDomainList(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDomainList(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	domains := []string{"panel.example.com", "*.example.org", "2001:db8::1"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"panel.example.com", true},
+		{"PANEL.example.com:8080", true},
+		{"panel.example.com.", true},
+		{"other.example.com", false},
+		{"example.com", false},
+		{"a.example.org", true},
+		{"a.b.example.org", true},
+		{"example.org", false},
+		{"evilexample.org", false},
+		{"[2001:db8::1]:8080", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := MatchDomain(domains, tt.host); got != tt.want {
+			t.Errorf("MatchDomain(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
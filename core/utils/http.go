@@ -0,0 +1,11 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+)
+
+// IsHTTPS 判断请求是否通过 HTTPS 到达（直连 TLS 或反向代理传递的 X-Forwarded-Proto），用于设置 cookie 的 Secure 属性
+func IsHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseIPList 解析逗号、空白或换行分隔的 IP/CIDR 列表，单个 IP 视为 /32 或 /128
+func ParseIPList(value string) ([]*net.IPNet, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+
+	nets := make([]*net.IPNet, 0, len(fields))
+	for _, field := range fields {
+		if strings.Contains(field, "/") {
+			_, ipNet, err := net.ParseCIDR(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR: %s", field)
+			}
+			nets = append(nets, ipNet)
+			continue
+		}
+
+		ip := net.ParseIP(field)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address: %s", field)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// IPListContains 判断 IP 是否落在任一网段内
+func IPListContains(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPNetwork 返回 IP 所在网段，IPv4 取 /24，IPv6 取 /48，用于粗略判断登录位置
+func IPNetwork(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return value
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseIPList(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		contains []string
+		excludes []string
+		wantErr  bool
+	}{
+		{"empty", "", nil, []string{"10.0.0.1"}, false},
+		{"single IPv4 is /32", "10.0.0.1", []string{"10.0.0.1"}, []string{"10.0.0.2"}, false},
+		{"IPv4 CIDR", "192.168.1.0/24", []string{"192.168.1.1", "192.168.1.255"}, []string{"192.168.2.1"}, false},
+		{"non-canonical CIDR", "192.168.1.77/24", []string{"192.168.1.1"}, []string{"192.168.0.1"}, false},
+		{"single IPv6 is /128", "2001:db8::1", []string{"2001:db8::1"}, []string{"2001:db8::2"}, false},
+		{"IPv6 CIDR", "2001:db8::/32", []string{"2001:db8:ffff::1"}, []string{"2001:db9::1"}, false},
+		{"IPv4-mapped IPv6 matches IPv4", "10.0.0.1", []string{"::ffff:10.0.0.1"}, nil, false},
+		{"mixed separators", "10.0.0.1, 10.0.0.2;10.0.0.3\n10.0.0.4\t10.0.0.5", []string{"10.0.0.1", "10.0.0.3", "10.0.0.5"}, []string{"10.0.0.6"}, false},
+		{"invalid IP", "10.0.0.256", nil, nil, true},
+		{"invalid CIDR", "10.0.0.0/33", nil, nil, true},
+		{"hostname", "example.com", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets, err := ParseIPList(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, ip := range tt.contains {
+				if !IPListContains(nets, net.ParseIP(ip)) {
+					t.Errorf("%s should be in %q", ip, tt.value)
+				}
+			}
+			for _, ip := range tt.excludes {
+				if IPListContains(nets, net.ParseIP(ip)) {
+					t.Errorf("%s should not be in %q", ip, tt.value)
+				}
+			}
+		})
+	}
+}
+
+func TestIPNetwork(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.77", "203.0.113.0/24"},
+		{"::ffff:203.0.113.77", "203.0.113.0/24"},
+		{"2001:db8:1:2:3::4", "2001:db8:1::/48"},
+		{"not-an-ip", "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := IPNetwork(tt.ip); got != tt.want {
+			t.Errorf("IPNetwork(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = func() map[string]bool {
+	passwords := make(map[string]bool)
+	for _, line := range strings.Split(commonPasswordsFile, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			passwords[line] = true
+		}
+	}
+	return passwords
+}()
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	// MinLength 最小长度
+	MinLength int
+	// RequireComplexity 是否要求大写字母、小写字母、数字、特殊字符中至少三类
+	RequireComplexity bool
+}
+
+// Validate 校验密码是否满足策略，不允许使用常见弱密码或与用户名相同
+func (p PasswordPolicy) Validate(password, username string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if commonPasswords[strings.ToLower(password)] {
+		return errors.New("password is too common")
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return errors.New("password must not be the same as the username")
+	}
+
+	if p.RequireComplexity {
+		var upper, lower, digit, special bool
+		for _, r := range password {
+			switch {
+			case unicode.IsUpper(r):
+				upper = true
+			case unicode.IsLower(r):
+				lower = true
+			case unicode.IsDigit(r):
+				digit = true
+			default:
+				special = true
+			}
+		}
+
+		classes := 0
+		for _, ok := range []bool{upper, lower, digit, special} {
+			if ok {
+				classes++
+			}
+		}
+		if classes < 3 {
+			return errors.New("password must contain at least three of: uppercase letters, lowercase letters, digits and symbols")
+		}
+	}
+	return nil
+}
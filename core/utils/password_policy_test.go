@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		username string
+		wantErr  bool
+	}{
+		{"long enough", PasswordPolicy{MinLength: 8}, "correct-horse", "alice", false},
+		{"too short", PasswordPolicy{MinLength: 8}, "Ab1!xyz", "alice", true},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "密码密码", "alice", false},
+		{"common password", PasswordPolicy{MinLength: 4}, "password", "alice", true},
+		{"common password ignores case", PasswordPolicy{MinLength: 4}, "PassWord", "alice", true},
+		{"same as username", PasswordPolicy{MinLength: 4}, "Alice-Admin", "alice-admin", true},
+		{"complexity with three classes", PasswordPolicy{MinLength: 8, RequireComplexity: true}, "Horse-battery", "alice", false},
+		{"complexity with two classes", PasswordPolicy{MinLength: 8, RequireComplexity: true}, "horsebattery9", "alice", true},
+		{"complexity with symbols only counted once", PasswordPolicy{MinLength: 8, RequireComplexity: true}, "horse-battery!", "alice", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(tt.password, tt.username); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate(%q) = %v, wantErr %v", tt.password, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashWithParams 使用指定参数生成 argon2id 哈希
+func hashWithParams(t *testing.T, password string, params PasswordParams) string {
+	t.Helper()
+
+	saved := DefaultPasswordParams
+	DefaultPasswordParams = params
+	defer func() { DefaultPasswordParams = saved }()
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestVerifyPasswordRehash(t *testing.T) {
+	current, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weak := DefaultPasswordParams
+	weak.Memory = 8 * 1024
+	weak.Iterations = 1
+	outdated := hashWithParams(t, "secret", weak)
+	longerSalt := DefaultPasswordParams
+	longerSalt.SaltLength = 32
+	outdatedSalt := hashWithParams(t, "secret", longerSalt)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		hash       string
+		wantMatch  bool
+		wantRehash bool
+		wantErr    bool
+	}{
+		{"current params", "secret", current, true, false, false},
+		{"current params wrong password", "wrong", current, false, false, false},
+		{"outdated cost", "secret", outdated, true, true, false},
+		{"outdated cost wrong password", "wrong", outdated, false, false, false},
+		{"outdated salt length", "secret", outdatedSalt, true, true, false},
+		{"bcrypt", "secret", string(legacy), true, true, false},
+		{"bcrypt wrong password", "wrong", string(legacy), false, false, false},
+		{"plaintext", "secret", "secret", false, false, true},
+		{"truncated argon2id", "secret", "$argon2id$v=19$m=65536,t=3,p=2$abc", false, false, true},
+		{"unsupported version", "secret", "$argon2id$v=16$m=65536,t=3,p=2$YWJj$YWJj", false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := VerifyPassword(tt.password, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if match != tt.wantMatch || rehash != tt.wantRehash {
+				t.Fatalf("match/rehash = %v/%v, want %v/%v", match, rehash, tt.wantMatch, tt.wantRehash)
+			}
+		})
+	}
+}
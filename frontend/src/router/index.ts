@@ -1,6 +1,7 @@
 import { createRouter, createWebHistory } from 'vue-router'
 import type { RouteRecordRaw } from 'vue-router'
 import Layout from '@/components/Layout.vue'
+import { isAuthenticated } from '@/utils/axios'
 
 const routes: RouteRecordRaw[] = [
   {
@@ -46,14 +47,8 @@ const router = createRouter({
   routes
 })
 
-// 检查 sessionkey 是否存在
-const checkSessionKey = (): boolean => {
-  return document.cookie.includes('sessionkey=')
-}
-
 router.beforeEach((to, from, next) => {
-  const token = localStorage.getItem('token')
-  const hasSessionKey = checkSessionKey()
+  const token = isAuthenticated()
 
   // 如果需要认证但没有 token
   if (to.meta.requiresAuth && !token) {
@@ -67,14 +62,6 @@ router.beforeEach((to, from, next) => {
     return
   }
 
-  // 如果访问登录页面但没有 sessionkey 且有 token（说明 sessionkey 已过期）
-  if (to.path === '/login' && !hasSessionKey && token) {
-    alert('登录已失效，请重新从安全入口进入登录页面')
-    localStorage.removeItem('token')
-    // 不跳转，让后端返回404页面
-    return
-  }
-
   next()
 })
 
@@ -5,13 +5,43 @@ const axiosInstance = axios.create({
   timeout: 10000,
 })
 
-// 请求拦截器 - 自动添加 token
+// saveAuth 保存登录结果，cookie 模式下访问 token 由浏览器保存在 HttpOnly cookie 中，只记录登录方式
+export const saveAuth = (data: any) => {
+  if (data.token) {
+    localStorage.setItem('token', data.token)
+    localStorage.removeItem('authMode')
+  } else if (data.authMode === 'cookie') {
+    localStorage.removeItem('token')
+    localStorage.setItem('authMode', 'cookie')
+  }
+}
+
+export const clearAuth = () => {
+  localStorage.removeItem('token')
+  localStorage.removeItem('authMode')
+}
+
+export const isAuthenticated = () => {
+  return !!localStorage.getItem('token') || localStorage.getItem('authMode') === 'cookie'
+}
+
+const readCookie = (name: string) => {
+  const item = document.cookie.split('; ').find((c) => c.startsWith(name + '='))
+  return item ? decodeURIComponent(item.slice(name.length + 1)) : ''
+}
+
+// 请求拦截器 - 自动添加 token，cookie 模式下为修改类请求添加 CSRF token
 axiosInstance.interceptors.request.use(
   (config) => {
     const token = localStorage.getItem('token')
     if (token) {
       config.headers.Authorization = `Bearer ${token}`
     }
+    const method = (config.method || 'get').toLowerCase()
+    const csrfToken = readCookie('gpanel_csrf')
+    if (csrfToken && !['get', 'head', 'options'].includes(method)) {
+      config.headers['X-CSRF-Token'] = csrfToken
+    }
     return config
   },
   (error) => {
@@ -19,15 +49,66 @@ axiosInstance.interceptors.request.use(
   }
 )
 
-// 响应拦截器 - 处理 401 错误
+// 刷新中的请求，多个请求同时过期时只刷新一次，页面中的其他刷新调用也需经过这里
+let refreshPromise: Promise<any> | null = null
+
+// refreshAccessToken 换发访问 token，返回登录结果
+export const refreshAccessToken = (): Promise<any> => {
+  if (!refreshPromise) {
+    // 刷新令牌保存在 HttpOnly cookie 中，由浏览器自动携带
+    // 其他标签页刚刚轮换了刷新令牌时，稍后使用新 cookie 重试一次
+    refreshPromise = axios
+      .post('/api/v1/auth/refresh')
+      .catch((error) => {
+        if (error.response?.status !== 409 || error.response?.data?.code !== 'refresh_retry') {
+          throw error
+        }
+        return new Promise((resolve) => setTimeout(resolve, 500)).then(() =>
+          axios.post('/api/v1/auth/refresh')
+        )
+      })
+      .then((response) => {
+        saveAuth(response.data)
+        return response.data
+      })
+      .finally(() => {
+        refreshPromise = null
+      })
+  }
+  return refreshPromise
+}
+
+const redirectToLogin = () => {
+  clearAuth()
+  window.location.href = '/login'
+}
+
+// 响应拦截器 - 访问 token 过期时刷新后重试，其他 401 错误回到登录页
 axiosInstance.interceptors.response.use(
   (response) => {
     return response
   },
-  (error) => {
+  async (error) => {
+    const config = error.config
+    const code = error.response?.data?.code
+
     if (error.response && error.response.status === 401) {
-      localStorage.removeItem('token')
-      window.location.href = '/login'
+      if (code === 'token_expired' && config && !config._retried) {
+        config._retried = true
+        try {
+          await refreshAccessToken()
+          delete config.headers.Authorization
+          return axiosInstance(config)
+        } catch {
+          redirectToLogin()
+          return Promise.reject(error)
+        }
+      }
+      redirectToLogin()
+    }
+    // 需要先修改密码或绑定双因素认证，回到登录页完成对应步骤
+    if (code === 'password_change_required' || code === '2fa_setup_required') {
+      redirectToLogin()
     }
     return Promise.reject(error)
   }